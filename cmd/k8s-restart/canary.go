@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/charmbracelet/log"
+)
+
+// soakCanary checks that the --canary nodes restarted without failures and
+// then, for --soak, that they stay Ready and that no pod on them starts
+// crash looping. It returns why the canary is unhealthy, if it is.
+func (r *rollout) soakCanary(nodes, failures []string) error {
+	c := r.report.StartCanary(nodes, r.cfg.Soak)
+	if len(failures) > 0 {
+		return c.Fail(fmt.Errorf("canary node(s) failed: %s", strings.Join(failures, ",")))
+	}
+	if r.cfg.DryRun {
+		c.Skip("dry-run")
+		log.Info("Canary soak skipped (dry-run)", "nodes", strings.Join(nodes, ","), "soak", r.cfg.Soak)
+		return nil
+	}
+
+	ctx := r.ctx
+	// Pods already crash looping when the soak starts are not held against
+	// the canary.
+	baseline := map[string]bool{}
+	for _, node := range nodes {
+		pods, err := r.kc.CrashLoopingPods(ctx, node)
+		if err != nil {
+			return c.Fail(fmt.Errorf("list pods on %s: %w", node, err))
+		}
+		for _, p := range pods {
+			baseline[p] = true
+		}
+	}
+
+	log.Info("Soaking canary nodes", "nodes", strings.Join(nodes, ","), "soak", r.cfg.Soak)
+	deadline := time.Now().Add(r.cfg.Soak)
+	interval := time.Duration(r.cfg.PollIntervalSeconds) * time.Second
+	for {
+		if err := checkCanary(ctx, r.kc, nodes, baseline); err != nil {
+			return c.Fail(err)
+		}
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return c.Fail(ctx.Err())
+		case <-time.After(min(max(interval, time.Second), left)):
+		}
+	}
+	c.Succeed()
+	log.Info("Canary healthy, starting the remaining nodes", "nodes", strings.Join(nodes, ","), "soak", r.cfg.Soak)
+	return nil
+}
+
+// checkCanary returns an error if a canary node is not Ready or has a pod
+// crash looping that is not in baseline.
+func checkCanary(ctx context.Context, kc *kube.Client, nodes []string, baseline map[string]bool) error {
+	for _, node := range nodes {
+		nd, err := kc.GetNode(ctx, node)
+		if err != nil {
+			return fmt.Errorf("get canary node %s: %w", node, err)
+		}
+		if !kube.IsNodeReady(nd) {
+			return fmt.Errorf("canary node %s is not Ready", node)
+		}
+		pods, err := kc.CrashLoopingPods(ctx, node)
+		if err != nil {
+			return fmt.Errorf("list pods on %s: %w", node, err)
+		}
+		var crashing []string
+		for _, p := range pods {
+			if !baseline[p] {
+				crashing = append(crashing, p)
+			}
+		}
+		if len(crashing) > 0 {
+			return fmt.Errorf("pods in CrashLoopBackOff on canary node %s: %s", node, strings.Join(crashing, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestRunBatchesCanary(t *testing.T) {
+	tests := []struct {
+		name             string
+		nodes            []string
+		dryRun           bool
+		wantNotAttempted []string
+		wantCanary       report.Status
+	}{
+		{name: "healthy canary", nodes: []string{"node1", "node2", "node3"}, wantCanary: report.StatusSucceeded},
+		{name: "failed canary node", nodes: []string{"ghost1", "node2", "node3"}, wantNotAttempted: []string{"node2", "node3"}, wantCanary: report.StatusFailed},
+		{name: "dry run", nodes: []string{"node1", "node2"}, dryRun: true, wantCanary: report.StatusSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DryRun:              tt.dryRun,
+				Nodes:               tt.nodes,
+				BatchSize:           2,
+				Canary:              1,
+				RebootMethod:        config.RebootMethodNone,
+				TimeoutReadySeconds: 1,
+			}
+			r := newTestRollout(cfg, "node1", "node2", "node3")
+
+			_, notAttempted := r.runBatches()
+			if strings.Join(notAttempted, ",") != strings.Join(tt.wantNotAttempted, ",") {
+				t.Errorf("notAttempted = %v, want %v", notAttempted, tt.wantNotAttempted)
+			}
+			r.report.Finish()
+			c := r.report.Canary
+			if c == nil || c.Status != tt.wantCanary || strings.Join(c.Nodes, ",") != tt.nodes[0] {
+				t.Fatalf("unexpected canary record %+v", c)
+			}
+			if r.report.Aborted != (tt.wantCanary == report.StatusFailed) {
+				t.Errorf("Aborted = %v", r.report.Aborted)
+			}
+		})
+	}
+}
+
+func TestRunBatchesCanaryCoversEveryNode(t *testing.T) {
+	cfg := &config.Config{Nodes: []string{"node1"}, BatchSize: 1, Canary: 1, RebootMethod: config.RebootMethodNone, TimeoutReadySeconds: 1}
+	r := newTestRollout(cfg, "node1")
+	r.runBatches()
+	if r.report.Canary != nil {
+		t.Errorf("soak ran without nodes left to protect: %+v", r.report.Canary)
+	}
+}
+
+func TestCheckCanary(t *testing.T) {
+	crashing := func(name string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       corev1.PodSpec{NodeName: "node1"},
+			Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+			}},
+		}
+	}
+	notReady := testNode("node1", false)
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+
+	tests := []struct {
+		name     string
+		objs     []runtime.Object
+		baseline map[string]bool
+		wantErr  string
+	}{
+		{name: "healthy", objs: []runtime.Object{testNode("node1", false)}},
+		{name: "not ready", objs: []runtime.Object{notReady}, wantErr: "not Ready"},
+		{name: "missing node", wantErr: "get canary node"},
+		{name: "new crash loop", objs: []runtime.Object{testNode("node1", false), crashing("web")}, wantErr: "default/web"},
+		{name: "crash loop from before the soak", objs: []runtime.Object{testNode("node1", false), crashing("web")}, baseline: map[string]bool{"default/web": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCanary(context.Background(), newFakeClient(tt.objs...), []string{"node1"}, tt.baseline)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkCanary() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkCanary() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/spf13/cobra"
+)
+
+// pluginCompletionName is the helper kubectl (1.26+) runs to complete
+// "kubectl reboot ..." on the command line. The binary can be installed or
+// symlinked under this name to act as that helper.
+const pluginCompletionName = "kubectl_complete-reboot"
+
+// pluginCompletionArgs rewrites the arguments when the binary was invoked as
+// the kubectl completion helper, turning them into a cobra __complete call.
+func pluginCompletionArgs(argv0 string, args []string) ([]string, bool) {
+	if filepath.Base(argv0) != pluginCompletionName {
+		return args, false
+	}
+	return append([]string{cobra.ShellCompRequestCmd}, args...), true
+}
+
+// registerNodeCompletions wires dynamic completion of node names for the
+// positional arguments and --exclude-nodes of a command that selects targets.
+func registerNodeCompletions(cmd *cobra.Command, cfg *config.Config) {
+	cmd.ValidArgsFunction = func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		kclient, err := newKubeClient(cfg)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nodeNameCompletions(kclient, args, toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	_ = cmd.RegisterFlagCompletionFunc("exclude-nodes", func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		kclient, err := newKubeClient(cfg)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nodeListCompletions(kclient, toComplete), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	})
+	if cmd.Flags().Lookup("output") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(
+			[]string{"json", "yaml"}, cobra.ShellCompDirectiveNoFileComp))
+	}
+	if cmd.Flags().Lookup("reboot-method") != nil {
+		_ = cmd.RegisterFlagCompletionFunc("reboot-method", cobra.FixedCompletions(
+			[]string{config.RebootMethodSSH, config.RebootMethodNone}, cobra.ShellCompDirectiveNoFileComp))
+	}
+}
+
+// registerKubeconfigCompletions completes --context, --cluster and --user
+// from the entries of the kubeconfig selected by the other flags.
+func registerKubeconfigCompletions(cmd *cobra.Command, cfg *config.Config) {
+	for flag, names := range map[string]func() []string{
+		"context": func() []string { return kubeconfigNames(cfg, "context") },
+		"cluster": func() []string { return kubeconfigNames(cfg, "cluster") },
+		"user":    func() []string { return kubeconfigNames(cfg, "user") },
+	} {
+		_ = cmd.RegisterFlagCompletionFunc(flag, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return filterPrefix(names(), toComplete), cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+}
+
+// nodeNameCompletions returns the cluster's node names that start with
+// toComplete and have not already been given as arguments.
+func nodeNameCompletions(kclient *kube.Client, args []string, toComplete string) []string {
+	names, err := kclient.ListNodeNames(false)
+	if err != nil {
+		return nil
+	}
+	given := map[string]struct{}{}
+	for _, a := range args {
+		given[a] = struct{}{}
+	}
+	var out []string
+	for _, n := range filterPrefix(names, toComplete) {
+		if _, ok := given[n]; !ok {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// nodeListCompletions completes the last element of a comma-separated list
+// of node names, keeping the elements already typed.
+func nodeListCompletions(kclient *kube.Client, toComplete string) []string {
+	done, last := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		done, last = toComplete[:i+1], toComplete[i+1:]
+	}
+	already := strings.Split(strings.TrimSuffix(done, ","), ",")
+	var out []string
+	for _, n := range nodeNameCompletions(kclient, already, last) {
+		out = append(out, done+n)
+	}
+	return out
+}
+
+// kubeconfigNames lists the context, cluster or user names in the kubeconfig.
+func kubeconfigNames(cfg *config.Config, kind string) []string {
+	raw, err := cfg.KubeFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	switch kind {
+	case "context":
+		for name := range raw.Contexts {
+			names = append(names, name)
+		}
+	case "cluster":
+		for name := range raw.Clusters {
+			names = append(names, name)
+		}
+	case "user":
+		for name := range raw.AuthInfos {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func filterPrefix(names []string, prefix string) []string {
+	var out []string
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
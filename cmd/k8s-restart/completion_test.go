@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func TestPluginCompletionArgs(t *testing.T) {
+	args, ok := pluginCompletionArgs("/usr/local/bin/kubectl_complete-reboot", []string{"--context", "pr"})
+	if !ok {
+		t.Fatal("expected kubectl_complete-reboot to be detected")
+	}
+	if strings.Join(args, " ") != cobra.ShellCompRequestCmd+" --context pr" {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	args, ok = pluginCompletionArgs("/usr/local/bin/kubectl-reboot", []string{"node1"})
+	if ok || strings.Join(args, " ") != "node1" {
+		t.Errorf("plain invocation should pass args through, got %v %v", args, ok)
+	}
+}
+
+func TestNodeNameCompletions(t *testing.T) {
+	kclient := newFakeClient(testNode("worker-1", false), testNode("worker-2", false), testNode("cp-1", false))
+
+	got := nodeNameCompletions(kclient, []string{"worker-1"}, "wor")
+	if strings.Join(got, ",") != "worker-2" {
+		t.Errorf("nodeNameCompletions() = %v, want [worker-2]", got)
+	}
+
+	got = nodeListCompletions(kclient, "worker-1,")
+	if strings.Join(got, " ") != "worker-1,cp-1 worker-1,worker-2" {
+		t.Errorf("nodeListCompletions() = %v", got)
+	}
+}
+
+func TestKubeconfigNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: prod
+  cluster: {server: https://prod.example.com}
+contexts:
+- name: prod-admin
+  context: {cluster: prod, user: admin}
+- name: dev-admin
+  context: {cluster: prod, user: admin}
+users:
+- name: admin
+  user: {token: secret}
+current-context: prod-admin
+`
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.New()
+	*cfg.KubeFlags.KubeConfig = path
+
+	if got := kubeconfigNames(cfg, "context"); strings.Join(got, ",") != "dev-admin,prod-admin" {
+		t.Errorf("contexts = %v", got)
+	}
+	if got := kubeconfigNames(cfg, "cluster"); strings.Join(got, ",") != "prod" {
+		t.Errorf("clusters = %v", got)
+	}
+	if got := filterPrefix(kubeconfigNames(cfg, "context"), "prod"); strings.Join(got, ",") != "prod-admin" {
+		t.Errorf("filtered contexts = %v", got)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"golang.org/x/term"
+)
+
+// errNotConfirmed is returned when the operator did not confirm the run.
+var errNotConfirmed = errors.New("run not confirmed, nothing was changed")
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// confirmRun shows the plan on out and asks the operator to type the name of
+// the kubeconfig context, or "yes" when there is none, before a run changes
+// anything. It only asks when interactive is set; --yes and --dry-run skip
+// it.
+func confirmRun(cfg *config.Config, kclient *kube.Client, in io.Reader, out io.Writer, interactive bool) error {
+	if cfg.Yes || cfg.DryRun || !interactive {
+		return nil
+	}
+	printPlan(cfg, out, evictablePods(cfg, kclient))
+
+	want := currentContext(cfg)
+	if want == "" {
+		want = "yes"
+	}
+	fmt.Fprintf(out, "\nType %q to restart these nodes: ", want)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return errNotConfirmed
+	}
+	if strings.TrimSpace(answer) != want {
+		return errNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfirmRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		context     string
+		yes         bool
+		dryRun      bool
+		interactive bool
+		input       string
+		wantErr     bool
+		wantPlan    bool
+	}{
+		{name: "not a terminal", interactive: false, context: "prod"},
+		{name: "yes flag", interactive: true, yes: true, context: "prod"},
+		{name: "dry run", interactive: true, dryRun: true, context: "prod"},
+		{name: "context typed", interactive: true, context: "prod", input: "prod\n", wantPlan: true},
+		{name: "context typed without newline", interactive: true, context: "prod", input: " prod", wantPlan: true},
+		{name: "wrong context", interactive: true, context: "prod", input: "staging\n", wantErr: true, wantPlan: true},
+		{name: "yes is not enough", interactive: true, context: "prod", input: "yes\n", wantErr: true, wantPlan: true},
+		{name: "no input", interactive: true, context: "prod", wantErr: true, wantPlan: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.New()
+			*cfg.KubeFlags.Context = tt.context
+			cfg.Nodes = []string{"node1"}
+			cfg.BatchSize = 1
+			cfg.RebootMethod = config.RebootMethodNone
+			cfg.Yes = tt.yes
+			cfg.DryRun = tt.dryRun
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}}
+			kclient := newFakeClient(testNode("node1", false), pod)
+
+			var out bytes.Buffer
+			err := confirmRun(cfg, kclient, strings.NewReader(tt.input), &out, tt.interactive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("confirmRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, errNotConfirmed) {
+				t.Errorf("confirmRun() error = %v, want errNotConfirmed", err)
+			}
+			if got := strings.Contains(out.String(), "Plan: 1 node(s)"); got != tt.wantPlan {
+				t.Errorf("plan shown = %v, want %v:\n%s", got, tt.wantPlan, out.String())
+			}
+			if tt.wantPlan && !strings.Contains(out.String(), `Type "prod"`) {
+				t.Errorf("prompt does not name the context:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestConfirmRunWithoutContext(t *testing.T) {
+	cfg := &config.Config{Nodes: []string{"node1"}, BatchSize: 1, RebootMethod: config.RebootMethodNone}
+	kclient := newFakeClient(testNode("node1", false))
+	var out bytes.Buffer
+	if err := confirmRun(cfg, kclient, strings.NewReader("yes\n"), &out, true); err != nil {
+		t.Fatalf("confirmRun() error = %v", err)
+	}
+	if !strings.Contains(out.String(), `Type "yes"`) {
+		t.Errorf("expected a yes prompt:\n%s", out.String())
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/rebootplan"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// controlPlaneSelector matches the nodes that are not control plane nodes.
+const controlPlaneSelector = "!node-role.kubernetes.io/control-plane,!node-role.kubernetes.io/master"
+
+func newControllerCommand(cfg *config.Config) *cobra.Command {
+	var resync time.Duration
+	cmd := &cobra.Command{
+		Use:   "controller [flags]",
+		Short: "Run in the cluster, restarting the nodes of NodeRebootPlan resources",
+		Long: `Run in the cluster, restarting the nodes of NodeRebootPlan resources.
+
+The controller lists the NodeRebootPlans every --resync-interval and runs
+the oldest unfinished plan the same way the reboot command would, reporting
+the progress of each node in the plan's status. The reboot flags given here
+are the defaults for every plan; the plan's spec overrides them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runController(cfg, resync)
+		},
+	}
+	cfg.AddBatchFlags(cmd.Flags())
+	cfg.AddRunFlags(cmd.Flags())
+	cfg.AddRebootFlags(cmd.Flags())
+	cfg.AddNotifyFlags(cmd.Flags())
+	cfg.AddLockFlags(cmd.Flags())
+	cfg.AddSilenceFlags(cmd.Flags())
+	cmd.Flags().DurationVar(&resync, "resync-interval", 30*time.Second, "how often to look for NodeRebootPlans to run")
+	return cmd
+}
+
+func runController(cfg *config.Config, resync time.Duration) error {
+	if err := cfg.Complete(nil); err != nil {
+		return err
+	}
+	if resync <= 0 {
+		return fmt.Errorf("--resync-interval must be positive, got %s", resync)
+	}
+	kclient, err := newKubeClient(cfg)
+	if err != nil {
+		return err
+	}
+	c := &controller{base: cfg, kc: kclient, identity: controllerIdentity(), self: os.Getenv("NODE_NAME")}
+	log.Info("Controller started", "identity", c.identity, "node", c.self, "resync_interval", resync)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(resync)
+	defer ticker.Stop()
+	for {
+		if err := c.reconcileAll(ctx); err != nil {
+			log.Error("Reconcile failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			log.Info("Controller stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// controllerIdentity names the controller pod in locks, cordons and
+// annotations.
+func controllerIdentity() string {
+	h, err := os.Hostname()
+	if err != nil {
+		h = "unknown"
+	}
+	return "controller@" + h
+}
+
+// controller runs NodeRebootPlans one at a time.
+type controller struct {
+	base     *config.Config
+	kc       *kube.Client
+	identity string
+	// self is the node the controller runs on, from the NODE_NAME
+	// environment variable. The controller never restarts it.
+	self string
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+func (c *controller) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// reconcileAll moves every unfinished plan forward, oldest first. Plans
+// are run one after another, never concurrently.
+func (c *controller) reconcileAll(ctx context.Context) error {
+	plans, err := rebootplan.List(ctx, c.kc.Dyn)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", rebootplan.Resource, err)
+	}
+	for _, p := range plans {
+		if p.Done() || ctx.Err() != nil {
+			continue
+		}
+		if err := c.reconcile(ctx, p); err != nil {
+			log.Error("Plan failed", "plan", p.Name, "error", err)
+			c.setPhase(ctx, p, rebootplan.PhaseFailed, err.Error())
+		}
+	}
+	return nil
+}
+
+// reconcile runs the nodes of p not yet done, if its window is open. An
+// error means the plan cannot run at all.
+func (c *controller) reconcile(ctx context.Context, p *rebootplan.NodeRebootPlan) error {
+	logger := log.With("plan", p.Name)
+	if p.Spec.Suspend {
+		if p.Status.Phase != rebootplan.PhaseSuspended {
+			logger.Info("Plan suspended")
+			c.setPhase(ctx, p, rebootplan.PhaseSuspended, "spec.suspend is set")
+		}
+		return nil
+	}
+	cfg, err := c.configFor(p)
+	if err != nil {
+		return err
+	}
+	nodes, err := c.planNodes(ctx, p)
+	if err != nil {
+		return err
+	}
+	// Draining its own node would stop the controller midway. The node is
+	// left to a replica running elsewhere, and the plan stays unfinished
+	// until one has restarted it.
+	var leftSelf string
+	if i := slices.Index(nodes, c.self); c.self != "" && i >= 0 {
+		nodes = slices.Delete(nodes, i, i+1)
+		leftSelf = "waiting for a controller on another node to restart " + c.self
+		if ns := p.Status.Node(c.self); ns.Status != string(report.StatusSkipped) {
+			logger.Warn("Skipping the node the controller runs on, it needs a controller on another node", "node", c.self)
+			ns.Status = string(report.StatusSkipped)
+			ns.Error = "the controller runs on this node"
+		}
+	}
+	if len(nodes) == 0 {
+		if leftSelf != "" {
+			if p.Status.Phase != rebootplan.PhasePending || p.Status.Message != leftSelf {
+				c.setPhase(ctx, p, rebootplan.PhasePending, leftSelf)
+			}
+			return nil
+		}
+		if p.Status.Count(string(report.StatusFailed)) > 0 {
+			c.finish(ctx, p, rebootplan.PhaseFailed, "one or more nodes failed")
+		} else {
+			c.finish(ctx, p, rebootplan.PhaseSucceeded, "all nodes restarted")
+		}
+		return nil
+	}
+	if cfg.MaxFailures > 0 && cfg.MaxFailures <= p.Status.Count(string(report.StatusFailed)) {
+		c.finish(ctx, p, rebootplan.PhaseAborted, "too many failures")
+		return nil
+	}
+	if cfg.MaxFailures > 0 {
+		cfg.MaxFailures -= p.Status.Count(string(report.StatusFailed))
+	}
+	cfg.Nodes = nodes
+
+	now := c.clock()
+	if cfg.Window != nil && !cfg.DryRun {
+		if opens, _ := cfg.Window.Next(now); opens.After(now) {
+			msg := "waiting for maintenance window opening at " + opens.Format(time.RFC3339)
+			if p.Status.Phase != rebootplan.PhaseWaiting || p.Status.Message != msg {
+				logger.Info("Waiting for maintenance window", "window", cfg.Window, "opens_at", opens.Format(time.RFC3339))
+				c.setPhase(ctx, p, rebootplan.PhaseWaiting, msg)
+			}
+			return nil
+		}
+	}
+
+	r := newRollout(cfg, c.kc, c.identity)
+	r.ctx = ctx
+	r.resumeStopped = true
+	release, err := r.acquireLock()
+	if err != nil {
+		var held *kube.LockHeldError
+		if errors.As(err, &held) {
+			logger.Info("Run lock held, retrying later", "holder", held.Holder)
+			phase := p.Status.Phase
+			if phase == "" {
+				phase = rebootplan.PhasePending
+			}
+			c.setPhase(ctx, p, phase, err.Error())
+			return nil
+		}
+		return err
+	}
+	defer release()
+
+	// The status must still be written once the controller is stopping.
+	status := &planStatus{ctx: context.WithoutCancel(ctx), c: c, name: p.Name, status: p.Status}
+	status.start(p, r.runID, nodes)
+	r.report.AddObserver(status)
+	r.notifier = r.newNotifier()
+	logger.Info("Plan started", "run_id", r.runID, "nodes", strings.Join(nodes, ","))
+
+	if r.notifier != nil {
+		r.notifier.RunStarted(cfg.Nodes)
+	}
+	failures, notAttempted := r.runBatches()
+	r.report.Finish()
+	aborted := len(notAttempted) > 0 && !r.report.WindowClosed
+	if r.notifier != nil {
+		r.notifier.RunFinished(r.report.Totals, failures, aborted)
+	}
+
+	switch {
+	case r.lockErr() != nil:
+		status.finish(rebootplan.PhasePending, r.lockErr().Error(), notAttempted)
+	case ctx.Err() != nil:
+		status.stopped(nodes)
+	case aborted:
+		status.finish(rebootplan.PhaseAborted, fmt.Sprintf("aborted after %d failures", len(failures)), notAttempted)
+	case len(notAttempted) > 0:
+		status.finish(rebootplan.PhaseWaiting, "maintenance window closed", notAttempted)
+	case len(failures) > 0 || status.failed():
+		status.finish(rebootplan.PhaseFailed, "one or more nodes failed", nil)
+	case leftSelf != "":
+		status.finish(rebootplan.PhasePending, leftSelf, nil)
+	default:
+		status.finish(rebootplan.PhaseSucceeded, "all nodes restarted", nil)
+	}
+	logger.Info("Plan run finished", "run_id", r.runID, "phase", status.status.Phase, "failed_count", len(failures), "not_attempted", len(notAttempted))
+	return nil
+}
+
+// configFor returns the controller's configuration with the overrides of
+// the plan's spec applied.
+func (c *controller) configFor(p *rebootplan.NodeRebootPlan) (*config.Config, error) {
+	cfg := *c.base
+	spec := p.Spec
+	cfg.ExcludeControlPlane = spec.ExcludeControlPlane
+	cfg.ExcludeNodes = spec.ExcludeNodes
+	if spec.Strategy.BatchSize > 0 {
+		cfg.BatchSize = spec.Strategy.BatchSize
+	}
+	if spec.Strategy.MaxFailures != nil {
+		cfg.MaxFailures = *spec.Strategy.MaxFailures
+	}
+	if spec.Strategy.CapacityCheck != "" {
+		cfg.CapacityCheck = spec.Strategy.CapacityCheck
+	}
+	if spec.Strategy.WaitWorkloads {
+		cfg.WaitWorkloads = true
+	}
+	if len(spec.Strategy.OnFailure) > 0 {
+		cfg.OnFailure = spec.Strategy.OnFailure
+	}
+	if len(spec.Windows) > 0 {
+		cfg.WindowSpec = strings.Join(spec.Windows, "; ")
+	}
+	if len(spec.HealthGates) > 0 {
+		cfg.HealthGateSpecs = spec.HealthGates
+	}
+	if spec.RebootMethod != "" {
+		cfg.RebootMethod = spec.RebootMethod
+	}
+	if spec.RebootCommand != "" {
+		cfg.RebootCmd = spec.RebootCommand
+	}
+	if spec.PreRebootCommand != "" {
+		cfg.PreRebootCmd = spec.PreRebootCommand
+	}
+	if spec.PostRebootCommand != "" {
+		cfg.PostRebootCmd = spec.PostRebootCommand
+	}
+	if spec.DryRun {
+		cfg.DryRun = true
+	}
+	if err := cfg.Complete(nil); err != nil {
+		return nil, fmt.Errorf("spec: %w", err)
+	}
+	return &cfg, nil
+}
+
+// planNodes returns the nodes selected by p, in name order, without those
+// already succeeded or failed in an earlier run of the plan.
+func (c *controller) planNodes(ctx context.Context, p *rebootplan.NodeRebootPlan) ([]string, error) {
+	spec := p.Spec
+	if spec.NodeSelector == nil && len(spec.Nodes) == 0 {
+		return nil, fmt.Errorf("spec: nodeSelector or nodes must be set")
+	}
+	selected := map[string]bool{}
+	if spec.NodeSelector != nil {
+		sel, err := metav1.LabelSelectorAsSelector(spec.NodeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("spec.nodeSelector: %w", err)
+		}
+		selector := sel.String()
+		if spec.ExcludeControlPlane {
+			selector = joinSelectors(selector, controlPlaneSelector)
+		}
+		names, err := c.kc.ListNodesMatching(ctx, selector)
+		if err != nil {
+			return nil, fmt.Errorf("list nodes: %w", err)
+		}
+		for _, n := range names {
+			selected[n] = true
+		}
+	}
+	for _, n := range spec.Nodes {
+		selected[n] = true
+	}
+	for _, n := range spec.ExcludeNodes {
+		delete(selected, n)
+	}
+	for _, ns := range p.Status.Nodes {
+		if ns.Status == string(report.StatusSucceeded) || ns.Status == string(report.StatusFailed) {
+			delete(selected, ns.Name)
+		}
+	}
+	nodes := make([]string, 0, len(selected))
+	for n := range selected {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+func joinSelectors(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "," + b
+}
+
+// setPhase records phase and message in the status of p.
+func (c *controller) setPhase(ctx context.Context, p *rebootplan.NodeRebootPlan, phase, message string) {
+	p.Status.Phase = phase
+	p.Status.Message = message
+	p.Status.ObservedGeneration = p.Generation
+	c.updateStatus(ctx, p.Name, p.Status)
+}
+
+// finish records that p reached a final phase.
+func (c *controller) finish(ctx context.Context, p *rebootplan.NodeRebootPlan, phase, message string) {
+	now := metav1.NewTime(c.clock())
+	p.Status.FinishedAt = &now
+	c.setPhase(ctx, p, phase, message)
+	log.Info("Plan finished", "plan", p.Name, "phase", phase)
+}
+
+func (c *controller) updateStatus(ctx context.Context, name string, status rebootplan.Status) {
+	if err := rebootplan.UpdateStatus(ctx, c.kc.Dyn, name, status); err != nil {
+		log.Warn("Failed to update plan status", "plan", name, "error", err)
+	}
+}
+
+// planStatus mirrors the run report into the status of a plan. To keep the
+// API server load low it is written when a node starts or finishes and when
+// the plan changes phase, not after every phase of a node.
+type planStatus struct {
+	ctx  context.Context
+	c    *controller
+	name string
+
+	mu     sync.Mutex
+	status rebootplan.Status
+}
+
+func (s *planStatus) start(p *rebootplan.NodeRebootPlan, runID string, nodes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := metav1.NewTime(s.c.clock())
+	s.status.Phase = rebootplan.PhaseRunning
+	s.status.Message = ""
+	s.status.ObservedGeneration = p.Generation
+	s.status.RunID = runID
+	if s.status.StartedAt == nil {
+		s.status.StartedAt = &now
+	}
+	for _, n := range nodes {
+		ns := s.status.Node(n)
+		ns.Status = rebootplan.NodePending
+		ns.Phase, ns.Error = "", ""
+		ns.StartedAt, ns.FinishedAt = nil, nil
+	}
+	s.push()
+}
+
+func (s *planStatus) PhaseEnded(node string, p *report.PhaseRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ns := s.status.Node(node)
+	ns.Phase = string(p.Name)
+	if ns.Status == string(report.StatusRunning) {
+		return
+	}
+	ns.Status = string(report.StatusRunning)
+	if ns.StartedAt == nil {
+		t := metav1.NewTime(p.StartedAt)
+		ns.StartedAt = &t
+	}
+	s.push()
+}
+
+func (s *planStatus) NodeFinished(n *report.NodeReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ns := s.status.Node(n.Name)
+	ns.Status = string(n.Status)
+	ns.Error = n.Error
+	if n.FinishedAt != nil {
+		t := metav1.NewTime(*n.FinishedAt)
+		ns.FinishedAt = &t
+	}
+	s.push()
+}
+
+// failed reports whether any node of the plan failed, in this run or an
+// earlier one.
+func (s *planStatus) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Count(string(report.StatusFailed)) > 0
+}
+
+// finish records the outcome of a run. Only the final phases set
+// FinishedAt; a plan stopped by its window resumes when it reopens, and one
+// that lost the run lock at a later resync.
+func (s *planStatus) finish(phase, message string, notAttempted []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range notAttempted {
+		s.status.Node(n).Status = string(report.StatusNotAttempted)
+	}
+	s.status.Phase = phase
+	s.status.Message = message
+	if phase != rebootplan.PhaseWaiting && phase != rebootplan.PhasePending {
+		now := metav1.NewTime(s.c.clock())
+		s.status.FinishedAt = &now
+	}
+	s.push()
+}
+
+// stopped records that the controller stopped during the run. The nodes
+// not done, including those stopped midway, go back to Pending for the next
+// run to pick up.
+func (s *planStatus) stopped(nodes []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range nodes {
+		ns := s.status.Node(n)
+		if ns.Status == string(report.StatusSucceeded) || ns.Status == string(report.StatusFailed) {
+			continue
+		}
+		ns.Status = rebootplan.NodePending
+		ns.Error, ns.FinishedAt = "", nil
+	}
+	s.status.Phase = rebootplan.PhasePending
+	s.status.Message = "controller stopped"
+	s.push()
+}
+
+func (s *planStatus) push() {
+	s.c.updateStatus(s.ctx, s.name, s.status)
+}
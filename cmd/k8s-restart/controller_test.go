@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/rebootplan"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestController returns a controller against a fake cluster holding
+// nodes and plans.
+func newTestController(t *testing.T, nodes []runtime.Object, plans ...*rebootplan.NodeRebootPlan) *controller {
+	t.Helper()
+	var objs []runtime.Object
+	for _, p := range plans {
+		p.APIVersion = rebootplan.Group + "/" + rebootplan.Version
+		p.Kind = rebootplan.Kind
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, &unstructured.Unstructured{Object: u})
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{rebootplan.GVR: rebootplan.Kind + "List"}, objs...)
+	base := &config.Config{
+		SSHHostTemplate: "%s",
+		RebootCmd:       config.DefaultRebootCmd,
+		RebootMethod:    config.RebootMethodSSH,
+		BatchSize:       config.DefaultBatchSize,
+		MaxFailures:     config.DefaultMaxFailures,
+	}
+	return &controller{
+		base:     base,
+		kc:       &kube.Client{CS: fake.NewSimpleClientset(nodes...), Dyn: dyn},
+		identity: "controller@test",
+	}
+}
+
+func getPlan(t *testing.T, c *controller, name string) *rebootplan.NodeRebootPlan {
+	t.Helper()
+	plans, err := rebootplan.List(context.Background(), c.kc.Dyn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range plans {
+		if p.Name == name {
+			return p
+		}
+	}
+	t.Fatalf("plan %s not found", name)
+	return nil
+}
+
+func TestControllerRunsPlan(t *testing.T) {
+	plan := &rebootplan.NodeRebootPlan{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+		Spec:       rebootplan.Spec{Nodes: []string{"node2", "node1"}, DryRun: true},
+	}
+	c := newTestController(t, []runtime.Object{testNode("node1", false), testNode("node2", false)}, plan)
+
+	if err := c.reconcileAll(context.Background()); err != nil {
+		t.Fatalf("reconcileAll() error = %v", err)
+	}
+	got := getPlan(t, c, "workers")
+	if got.Status.Phase != rebootplan.PhaseSucceeded || got.Status.FinishedAt == nil || got.Status.RunID == "" {
+		t.Fatalf("unexpected status %+v", got.Status)
+	}
+	if len(got.Status.Nodes) != 2 {
+		t.Fatalf("expected 2 node statuses, got %+v", got.Status.Nodes)
+	}
+	for _, ns := range got.Status.Nodes {
+		if ns.Status != string(report.StatusSucceeded) || ns.Phase != string(report.PhaseUncordon) || ns.FinishedAt == nil {
+			t.Errorf("unexpected node status %+v", ns)
+		}
+	}
+	// Plan start, each node's start and finish, plan finish.
+	if got, want := statusPatches(c), 6; got != want {
+		t.Errorf("status patched %d times, want %d", got, want)
+	}
+
+	// A finished plan is left alone.
+	if err := c.reconcileAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if again := getPlan(t, c, "workers"); again.Status.RunID != got.Status.RunID {
+		t.Error("finished plan was run again")
+	}
+}
+
+func TestControllerSkipsOwnNode(t *testing.T) {
+	plan := &rebootplan.NodeRebootPlan{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+		Spec:       rebootplan.Spec{Nodes: []string{"node1", "node2"}, DryRun: true},
+	}
+	c := newTestController(t, []runtime.Object{testNode("node1", false), testNode("node2", false)}, plan)
+	c.self = "node1"
+
+	if err := c.reconcileAll(context.Background()); err != nil {
+		t.Fatalf("reconcileAll() error = %v", err)
+	}
+	got := getPlan(t, c, "workers")
+	if got.Status.Phase != rebootplan.PhasePending || !strings.Contains(got.Status.Message, "node1") || got.Status.FinishedAt != nil {
+		t.Errorf("unexpected status %+v", got.Status)
+	}
+	if ns := got.Status.Node("node1"); ns.Status != string(report.StatusSkipped) || ns.Error == "" {
+		t.Errorf("unexpected status of the controller's node %+v", ns)
+	}
+	if ns := got.Status.Node("node2"); ns.Status != string(report.StatusSucceeded) {
+		t.Errorf("node2 status = %s, want %s", ns.Status, report.StatusSucceeded)
+	}
+
+	// Run again on the same node, the plan keeps waiting without a new
+	// status update.
+	patches := statusPatches(c)
+	if err := c.reconcileAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if statusPatches(c) != patches {
+		t.Error("waiting plan status rewritten")
+	}
+
+	// A controller on another node finishes the plan.
+	c.self = "node3"
+	if err := c.reconcileAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got = getPlan(t, c, "workers")
+	if got.Status.Phase != rebootplan.PhaseSucceeded {
+		t.Errorf("phase = %s, want %s", got.Status.Phase, rebootplan.PhaseSucceeded)
+	}
+	if ns := got.Status.Node("node1"); ns.Status != string(report.StatusSucceeded) {
+		t.Errorf("node1 status = %s, want %s", ns.Status, report.StatusSucceeded)
+	}
+}
+
+func TestControllerPlanStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      rebootplan.Spec
+		status    rebootplan.Status
+		wantPhase string
+		wantMsg   string
+	}{
+		{
+			name:      "suspended",
+			spec:      rebootplan.Spec{Nodes: []string{"node1"}, Suspend: true},
+			wantPhase: rebootplan.PhaseSuspended,
+		},
+		{
+			name:      "outside window",
+			spec:      rebootplan.Spec{Nodes: []string{"node1"}, Windows: []string{"Mon 01:00-05:00 UTC"}},
+			wantPhase: rebootplan.PhaseWaiting,
+			wantMsg:   "2024-01-08T01:00:00Z",
+		},
+		{
+			name:      "no nodes selected",
+			spec:      rebootplan.Spec{},
+			wantPhase: rebootplan.PhaseFailed,
+			wantMsg:   "nodeSelector or nodes must be set",
+		},
+		{
+			name:      "invalid spec",
+			spec:      rebootplan.Spec{Nodes: []string{"node1"}, Windows: []string{"25:00-26:00"}},
+			wantPhase: rebootplan.PhaseFailed,
+		},
+		{
+			name: "every node done",
+			spec: rebootplan.Spec{Nodes: []string{"node1"}},
+			status: rebootplan.Status{Phase: rebootplan.PhaseRunning, Nodes: []rebootplan.NodeStatus{
+				{Name: "node1", Status: string(report.StatusFailed)},
+			}},
+			wantPhase: rebootplan.PhaseFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := &rebootplan.NodeRebootPlan{ObjectMeta: metav1.ObjectMeta{Name: "p"}, Spec: tt.spec, Status: tt.status}
+			c := newTestController(t, []runtime.Object{testNode("node1", false)}, plan)
+			// Tuesday, outside the Monday window.
+			c.now = func() time.Time { return time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC) }
+
+			if err := c.reconcileAll(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := getPlan(t, c, "p")
+			if got.Status.Phase != tt.wantPhase || !strings.Contains(got.Status.Message, tt.wantMsg) {
+				t.Errorf("status = %s %q, want %s containing %q", got.Status.Phase, got.Status.Message, tt.wantPhase, tt.wantMsg)
+			}
+			nd, err := c.kc.GetNode(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Spec.Unschedulable {
+				t.Error("node1 was cordoned")
+			}
+		})
+	}
+}
+
+func TestControllerStopped(t *testing.T) {
+	plan := &rebootplan.NodeRebootPlan{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+		Spec:       rebootplan.Spec{Nodes: []string{"node1"}},
+	}
+	c := newTestController(t, []runtime.Object{testNode("node1", false)}, plan)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := c.reconcile(ctx, plan); err != nil {
+		t.Fatalf("reconcile() error = %v", err)
+	}
+	got := getPlan(t, c, "workers")
+	if got.Status.Phase != rebootplan.PhasePending || got.Status.Message != "controller stopped" || got.Status.FinishedAt != nil {
+		t.Errorf("unexpected status %+v", got.Status)
+	}
+	if ns := got.Status.Node("node1"); ns.Status != rebootplan.NodePending {
+		t.Errorf("node1 status = %s, want %s", ns.Status, rebootplan.NodePending)
+	}
+	nd, err := c.kc.GetNode(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Spec.Unschedulable {
+		t.Error("node1 was cordoned after the controller stopped")
+	}
+}
+
+// statusPatches counts the status updates the controller sent.
+func statusPatches(c *controller) int {
+	n := 0
+	for _, a := range c.kc.Dyn.(*dynamicfake.FakeDynamicClient).Actions() {
+		if a.GetVerb() == "patch" && a.GetSubresource() == "status" {
+			n++
+		}
+	}
+	return n
+}
+
+func TestControllerConfigFor(t *testing.T) {
+	c := newTestController(t, nil)
+	maxFailures := 0
+	cfg, err := c.configFor(&rebootplan.NodeRebootPlan{Spec: rebootplan.Spec{
+		Windows:     []string{"Sat 00:00-06:00 UTC", "Sun 00:00-06:00 UTC"},
+		HealthGates: []string{"daemonsets"},
+		Strategy: rebootplan.Strategy{
+			BatchSize:   3,
+			MaxFailures: &maxFailures,
+			OnFailure:   map[string]string{"drain": config.OnFailureTaint},
+		},
+		RebootMethod: config.RebootMethodNone,
+	}})
+	if err != nil {
+		t.Fatalf("configFor() error = %v", err)
+	}
+	if cfg.BatchSize != 3 || cfg.MaxFailures != 0 || cfg.RebootMethod != config.RebootMethodNone {
+		t.Errorf("strategy not applied: batch=%d max=%d method=%s", cfg.BatchSize, cfg.MaxFailures, cfg.RebootMethod)
+	}
+	if cfg.Window == nil || cfg.Window.String() != "Sat 00:00-06:00 UTC; Sun 00:00-06:00 UTC" {
+		t.Errorf("window = %v", cfg.Window)
+	}
+	if len(cfg.HealthGates) != 1 || cfg.FailurePolicy("drain") != config.OnFailureTaint {
+		t.Errorf("gates or failure policy not applied: %+v %v", cfg.HealthGates, cfg.OnFailure)
+	}
+	if c.base.BatchSize != config.DefaultBatchSize || c.base.Window != nil {
+		t.Error("configFor modified the controller's configuration")
+	}
+}
+
+func TestControllerPlanNodes(t *testing.T) {
+	worker := func(name string) runtime.Object {
+		n := testNode(name, false)
+		n.Labels = map[string]string{"pool": "workers"}
+		return n
+	}
+	cp := testNode("cp1", false)
+	cp.Labels = map[string]string{"pool": "workers", "node-role.kubernetes.io/control-plane": ""}
+	c := newTestController(t, []runtime.Object{worker("w1"), worker("w2"), worker("w3"), cp, testNode("other", false)})
+
+	got, err := c.planNodes(context.Background(), &rebootplan.NodeRebootPlan{
+		Spec: rebootplan.Spec{
+			NodeSelector:        &metav1.LabelSelector{MatchLabels: map[string]string{"pool": "workers"}},
+			Nodes:               []string{"extra"},
+			ExcludeNodes:        []string{"w2"},
+			ExcludeControlPlane: true,
+		},
+		Status: rebootplan.Status{Nodes: []rebootplan.NodeStatus{
+			{Name: "w3", Status: string(report.StatusSucceeded)},
+			{Name: "extra", Status: string(report.StatusNotAttempted)},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("planNodes() error = %v", err)
+	}
+	if want := []string{"extra", "w1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("planNodes() = %v, want %v", got, want)
+	}
+}
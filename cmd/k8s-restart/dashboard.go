@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/dashboard"
+	"github.com/charmbracelet/log"
+)
+
+// useDashboard reports whether a run should show the live dashboard instead
+// of plain logs. It needs text logging and a terminal on stdout, and stays
+// off when the report goes to stdout or the run pauses for input between
+// batches.
+func useDashboard(cfg *config.Config, stdoutTerminal bool) bool {
+	if cfg.NoDashboard || cfg.Output != "" || cfg.Interactive || !stdoutTerminal {
+		return false
+	}
+	return cfg.LogFormat == "" || cfg.LogFormat == "text"
+}
+
+// newDashboard prepares the live dashboard for the run's target nodes. Log
+// lines go on to stderr as they are written, unless stderr is the terminal
+// the dashboard is drawn on; then they go to a log file whose path is logged
+// when the dashboard starts.
+func (r *rollout) newDashboard(stderrTerminal bool) (*dashboard.Dashboard, error) {
+	d := &dashboard.Dashboard{
+		Out:      os.Stdout,
+		Log:      os.Stderr,
+		Report:   r.report,
+		Nodes:    r.cfg.Nodes,
+		PodsLeft: r.kc.CountEvictablePods,
+	}
+	if stderrTerminal {
+		f, err := os.CreateTemp("", "kubectl-reboot-"+r.runID+"-*.log")
+		if err != nil {
+			return nil, fmt.Errorf("create log file: %w", err)
+		}
+		d.Log = f
+		r.dashLog = f
+	}
+	return d, nil
+}
+
+// startDashboard shows the live dashboard on stdout, sending log output
+// through it. It does nothing without a dashboard.
+func (r *rollout) startDashboard() {
+	if r.dash == nil {
+		return
+	}
+	if r.dashLog != nil {
+		log.Info("Logging to a file while the dashboard is shown", "path", r.dashLog.Name())
+	}
+	log.SetOutput(r.dash)
+	r.dash.Start()
+}
+
+// stopDashboard prints the summary table and sends log output back to
+// stderr. It does nothing without a dashboard or once it has run.
+func (r *rollout) stopDashboard() {
+	if r.dash == nil || !r.dash.Stop() {
+		return
+	}
+	log.SetOutput(os.Stderr)
+	if r.dashLog != nil {
+		if err := r.dashLog.Close(); err != nil {
+			log.Warn("Failed to close log file", "path", r.dashLog.Name(), "error", err)
+		}
+		log.Info("Run log written", "path", r.dashLog.Name())
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+)
+
+func TestUseDashboard(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		terminal bool
+		want     bool
+	}{
+		{name: "text logs on a terminal", cfg: config.Config{LogFormat: "text"}, terminal: true, want: true},
+		{name: "default log format", terminal: true, want: true},
+		{name: "not a terminal", cfg: config.Config{LogFormat: "text"}},
+		{name: "json logs", cfg: config.Config{LogFormat: "json"}, terminal: true},
+		{name: "logfmt logs", cfg: config.Config{LogFormat: "logfmt"}, terminal: true},
+		{name: "report on stdout", cfg: config.Config{Output: "json"}, terminal: true},
+		{name: "interactive", cfg: config.Config{Interactive: true}, terminal: true},
+		{name: "disabled", cfg: config.Config{NoDashboard: true}, terminal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useDashboard(&tt.cfg, tt.terminal); got != tt.want {
+				t.Errorf("useDashboard() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDashboardLog(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	r := newTestRollout(&config.Config{Nodes: []string{"node1"}}, "node1")
+	r.runID = "abc123"
+
+	d, err := r.newDashboard(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Log != os.Stderr || r.dashLog != nil {
+		t.Errorf("logs go to %v, want stderr when it is not a terminal", d.Log)
+	}
+
+	d, err = r.newDashboard(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.dashLog == nil || d.Log != r.dashLog || !strings.Contains(filepath.Base(r.dashLog.Name()), "abc123") {
+		t.Fatalf("logs go to %v, want a log file for the run", d.Log)
+	}
+	fmt.Fprintln(d, "INFO Node cordoned node=node1")
+	_ = r.dashLog.Close()
+	got, err := os.ReadFile(r.dashLog.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "INFO Node cordoned node=node1\n" {
+		t.Errorf("log file = %q", got)
+	}
+}
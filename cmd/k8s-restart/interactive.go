@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/charmbracelet/log"
+)
+
+// What the operator chose to do after a batch with --interactive.
+type stepChoice int
+
+const (
+	stepContinue stepChoice = iota
+	stepSkip
+	stepAbort
+	stepRetry
+)
+
+// stepper asks the operator how to go on between batches.
+type stepper struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func newStepper(in io.Reader, out io.Writer) *stepper {
+	return &stepper{in: bufio.NewReader(in), out: out}
+}
+
+// stepAfterBatch shows the nodes of the batch that just finished and asks
+// whether to continue, skip the next batch, abort or retry the failed nodes.
+// Retries happen here; nodes that succeed on retry are removed from failed.
+func (r *rollout) stepAfterBatch(batch, batches int, nodes []string, failed *[]string, next []string) stepChoice {
+	for {
+		r.step.summary(r.report, batch, batches, nodes)
+		choice := r.step.ask(len(*failed) > 0, next)
+		if choice != stepRetry {
+			return choice
+		}
+		log.Info("Retrying failed nodes", "nodes", strings.Join(*failed, ","))
+		if !r.cfg.DryRun {
+			for _, node := range *failed {
+				if _, err := r.kc.RemoveTaint(context.Background(), node, kube.TaintFailed); err != nil {
+					log.Warn("Failed to remove failure taint", "node", node, "error", err)
+				}
+			}
+		}
+		*failed = r.runBatch(*failed)
+	}
+}
+
+// summary writes the status and phase timings of the given nodes.
+func (s *stepper) summary(rep *report.Report, batch, batches int, nodes []string) {
+	fmt.Fprintf(s.out, "\nBatch %d/%d finished\n", batch, batches)
+	tw := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tSTATUS\tDURATION\tPHASES")
+	for _, node := range nodes {
+		nr := rep.Lookup(node)
+		if nr == nil {
+			continue
+		}
+		duration := "-"
+		if nr.StartedAt != nil && nr.FinishedAt != nil {
+			duration = nr.FinishedAt.Sub(*nr.StartedAt).Round(time.Second).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", node, nr.Status, duration, phaseTimings(nr))
+	}
+	_ = tw.Flush()
+	for _, node := range nodes {
+		if nr := rep.Lookup(node); nr != nil && nr.Error != "" {
+			fmt.Fprintf(s.out, "%s: %s\n", node, nr.Error)
+		}
+	}
+}
+
+// phaseTimings lists the phases of a node that ran, with their durations.
+func phaseTimings(nr *report.NodeReport) string {
+	var parts []string
+	for _, p := range nr.Phases {
+		switch p.Status {
+		case report.StatusSkipped:
+			continue
+		case report.StatusFailed:
+			parts = append(parts, fmt.Sprintf("%s failed after %s", p.Name, p.Duration().Round(time.Second)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s %s", p.Name, p.Duration().Round(time.Second)))
+		}
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ask prompts until the operator picks one of the offered choices. Retry
+// is only offered when nodes failed and skip only when a batch follows. A
+// closed input aborts.
+func (s *stepper) ask(canRetry bool, next []string) stepChoice {
+	options := []string{"[c]ontinue"}
+	if len(next) > 0 {
+		options = append(options, fmt.Sprintf("[s]kip next batch (%s)", strings.Join(next, ",")))
+	}
+	if canRetry {
+		options = append(options, "[r]etry failed")
+	}
+	options = append(options, "[a]bort")
+	for {
+		fmt.Fprintf(s.out, "%s? ", strings.Join(options, ", "))
+		line, err := s.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		switch {
+		case answer == "c" || answer == "continue":
+			return stepContinue
+		case (answer == "s" || answer == "skip") && len(next) > 0:
+			return stepSkip
+		case (answer == "r" || answer == "retry") && canRetry:
+			return stepRetry
+		case answer == "a" || answer == "abort":
+			return stepAbort
+		}
+		if err != nil {
+			fmt.Fprintln(s.out)
+			return stepAbort
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/charmbracelet/log"
+)
+
+func init() {
+	log.SetLevel(log.InfoLevel)
+	log.SetTimeFormat("")
+	log.SetReportTimestamp(false)
+	log.SetFormatter(log.TextFormatter)
+}
+
+// configureLogging applies --log-format, --log-level and --log-timestamps to
+// the default logger, which kube.Client and ssh.Runner log through as well.
+func configureLogging(cfg *config.Config) error {
+	formatter, err := parseLogFormat(cfg.LogFormat)
+	if err != nil {
+		return err
+	}
+	level, err := log.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("--log-level: %w", err)
+	}
+
+	log.SetFormatter(formatter)
+	log.SetLevel(level)
+	log.SetReportTimestamp(cfg.LogTimestamps)
+	if cfg.LogTimestamps {
+		log.SetTimeFormat(time.RFC3339)
+	} else {
+		log.SetTimeFormat("")
+	}
+	return nil
+}
+
+func parseLogFormat(format string) (log.Formatter, error) {
+	switch format {
+	case "", "text":
+		return log.TextFormatter, nil
+	case "json":
+		return log.JSONFormatter, nil
+	case "logfmt":
+		return log.LogfmtFormatter, nil
+	}
+	return 0, fmt.Errorf("--log-format must be text, json or logfmt, got %q", format)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/charmbracelet/log"
+)
+
+func TestConfigureLogging(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		_ = configureLogging(&config.Config{LogFormat: config.DefaultLogFormat, LogLevel: config.DefaultLogLevel})
+	}()
+
+	cfg := &config.Config{LogFormat: "json", LogLevel: "warn", LogTimestamps: true}
+	if err := configureLogging(cfg); err != nil {
+		t.Fatalf("configureLogging() error = %v", err)
+	}
+
+	log.Info("Node cordoned", "node", "node1")
+	if buf.Len() != 0 {
+		t.Errorf("info message should be filtered at warn level, got %q", buf.String())
+	}
+
+	log.Warn("SSH reboot command failed", "node", "node1", "phase", "reboot")
+	var entry map[string]any
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &entry); err != nil {
+		t.Fatalf("expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+	if entry["msg"] != "SSH reboot command failed" || entry["node"] != "node1" || entry["phase"] != "reboot" {
+		t.Errorf("unexpected log entry %v", entry)
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("expected a timestamp with --log-timestamps, got %v", entry)
+	}
+
+	buf.Reset()
+	if err := configureLogging(&config.Config{LogFormat: "logfmt", LogLevel: "info"}); err != nil {
+		t.Fatalf("configureLogging() error = %v", err)
+	}
+	log.Info("Node is ready", "node", "node1")
+	if got := strings.TrimSpace(buf.String()); got != `level=info msg="Node is ready" node=node1` {
+		t.Errorf("unexpected logfmt line %q", got)
+	}
+}
+
+func TestConfigureLoggingInvalid(t *testing.T) {
+	if err := configureLogging(&config.Config{LogFormat: "xml", LogLevel: "info"}); err == nil {
+		t.Error("Expected error for unknown log format")
+	}
+	if err := configureLogging(&config.Config{LogFormat: "text", LogLevel: "chatty"}); err == nil {
+		t.Error("Expected error for unknown log level")
+	}
+}
@@ -2,67 +2,107 @@ package main
 
 import (
 	"bufio"
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/ayetkin/kubectl-reboot/internal/config"
 	"github.com/ayetkin/kubectl-reboot/internal/kube"
-	sshpkg "github.com/ayetkin/kubectl-reboot/internal/ssh"
 	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
 )
 
-func init() {
-	log.SetLevel(log.InfoLevel)
-	log.SetTimeFormat("")
-	log.SetReportTimestamp(false)
-	log.SetFormatter(log.TextFormatter)
-}
-
 func main() {
-	cfg := config.Parse()
-	kclient, err := kube.New(cfg.KubeconfigPath, cfg.KubeContext, log.Default())
-	if err != nil {
-		log.Fatalf("kube client: %v", err)
+	cmd := newRootCommand()
+	if args, ok := pluginCompletionArgs(os.Args[0], os.Args[1:]); ok {
+		cmd.SetArgs(args)
 	}
-
-	// Process node configuration
-	if err := processNodeConfiguration(cfg, kclient); err != nil {
-		log.Fatal(err.Error())
-	}
-
-	// Filter excluded nodes
-	if len(cfg.ExcludeNodes) > 0 {
-		if err := filterExcludedNodes(cfg); err != nil {
-			log.Fatal(err.Error())
+	if err := cmd.Execute(); err != nil {
+		if !errors.Is(err, errNodesFailed) && !errors.Is(err, errRunAborted) && !errors.Is(err, errInterrupted) {
+			log.Error(err.Error())
 		}
+		os.Exit(exitCode(err))
 	}
+}
 
-	// Log configuration and start operations
-	logConfiguration(cfg)
+// Exit codes distinguishing how a run ended.
+const (
+	exitError          = 1   // the run failed to start, or some nodes failed
+	exitAborted        = 3   // --max-failures stopped the run early
+	exitInterruptedRun = 130 // SIGINT or SIGTERM stopped the run
+)
 
-	sshRunner := &sshpkg.Runner{DryRun: cfg.DryRun, Opts: cfg.SSHOpts, Key: cfg.SSHIdentityFile}
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errRunAborted):
+		return exitAborted
+	case errors.Is(err, errInterrupted):
+		return exitInterruptedRun
+	}
+	return exitError
+}
 
-	log.Info("⏳ Initial wait before starting operations", "seconds", 5)
-	time.Sleep(5 * time.Second)
+func newRootCommand() *cobra.Command {
+	cfg := config.New()
+	cmd := &cobra.Command{
+		Use:   "kubectl-reboot [flags] [NODE_NAMES...]",
+		Short: "Safely restart Kubernetes nodes",
+		Long: `Safely restart Kubernetes nodes by draining pods, rebooting via SSH,
+verifying the reboot, and uncordoning the nodes.
+
+Running without a subcommand is the same as "reboot".`,
+		Example:       rebootExample,
+		Version:       versionString(),
+		Args:          cobra.ArbitraryArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			return configureLogging(cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRebootCommand(cfg, args)
+		},
+	}
+	cfg.AddKubeFlags(cmd.PersistentFlags())
+	cfg.AddLogFlags(cmd.PersistentFlags())
+	addRebootFlags(cmd, cfg)
+	registerKubeconfigCompletions(cmd, cfg)
+
+	cmd.AddCommand(
+		newRebootCommand(cfg),
+		newPlanCommand(cfg),
+		newStatusCommand(cfg),
+		newRecoverCommand(cfg),
+		newControllerCommand(cfg),
+		newVersionCommand(),
+	)
+	return cmd
+}
 
-	// Process all nodes
-	var failures []string
-	for _, node := range cfg.Nodes {
-		if err := processNode(cfg, kclient, sshRunner, node); err != nil {
-			log.Error("❌ Node processing failed", "node", node, "error", err)
-			failures = append(failures, node)
-		}
+// newKubeClient builds the API client from the shared kubectl flags.
+func newKubeClient(cfg *config.Config) (*kube.Client, error) {
+	kclient, err := kube.New(cfg.KubeFlags, log.Default())
+	if err != nil {
+		return nil, fmt.Errorf("kube client: %v", err)
 	}
+	return kclient, nil
+}
 
-	if len(failures) > 0 {
-		failuresList := "    " + strings.Join(failures, "\n    ")
-		log.Error("💥 Operation failed", "failed_count", len(failures), "failed_nodes", failuresList)
-		os.Exit(1)
+// resolveTargets fills cfg.Nodes from the positional arguments, --all or
+// --file and applies --exclude-nodes. When allByDefault is set, an empty
+// selection means every node instead of an error.
+func resolveTargets(cfg *config.Config, kclient *kube.Client, allByDefault bool) error {
+	if allByDefault && !cfg.AllNodes && cfg.File == "" && len(cfg.Nodes) == 0 {
+		cfg.AllNodes = true
+	}
+	if err := processNodeConfiguration(cfg, kclient); err != nil {
+		return err
+	}
+	if len(cfg.ExcludeNodes) > 0 {
+		return filterExcludedNodes(cfg)
 	}
-	log.Info("🎉 All nodes processed successfully! Operation completed.")
+	return nil
 }
 
 func processNodeConfiguration(cfg *config.Config, kclient *kube.Client) error {
@@ -73,11 +113,12 @@ func processNodeConfiguration(cfg *config.Config, kclient *kube.Client) error {
 		}
 		cfg.Nodes = nodes
 	} else if cfg.File != "" {
-		fileNodes, err := readNodesFile(cfg.File)
+		fileNodes, overrides, err := readNodesFile(cfg.File)
 		if err != nil {
 			return fmt.Errorf("nodes file: %v", err)
 		}
 		cfg.Nodes = append(cfg.Nodes, fileNodes...)
+		cfg.NodeOverrides = overrides
 	}
 
 	if len(cfg.Nodes) == 0 {
@@ -105,10 +146,9 @@ func filterExcludedNodes(cfg *config.Config) error {
 	cfg.Nodes = filtered
 
 	if len(excluded) > 0 {
-		excludedList := "    " + strings.Join(excluded, "\n    ")
-		log.Info("🚫 Excluded nodes", "count", len(excluded), "nodes", excludedList)
+		log.Info("Excluded nodes", "count", len(excluded), "nodes", strings.Join(excluded, ","))
 	} else {
-		log.Infof("⚠️  --exclude-nodes provided but none matched the target node list")
+		log.Info("No target nodes matched --exclude-nodes")
 	}
 
 	missing := make([]string, 0)
@@ -122,115 +162,33 @@ func filterExcludedNodes(cfg *config.Config) error {
 		}
 	}
 	if len(missing) > 0 {
-		missingList := "    " + strings.Join(missing, "\n    ")
-		log.Warn("❓ Exclude nodes not found in target set", "missing", missingList)
+		log.Warn("Exclude nodes not found in target set", "missing", strings.Join(missing, ","))
 	}
 	if len(cfg.Nodes) == 0 {
-		return fmt.Errorf("❌ All nodes were excluded - no nodes to process")
+		return fmt.Errorf("all nodes were excluded - no nodes to process")
 	}
 	return nil
 }
 
-func logConfiguration(cfg *config.Config) {
-	log.Info("🚀 Starting k8s-restart operation")
-
-	// Format nodes list
-	nodesList := strings.Join(cfg.Nodes, "\n    ")
-	log.Info("📋 Target nodes", "count", len(cfg.Nodes), "nodes", "    "+nodesList)
-	log.Info("🔧 Drain arguments", "args", cfg.DrainArgs)
-	log.Info("🔑 SSH options", "opts", cfg.SSHOpts)
-	if cfg.SSHIdentityFile != "" {
-		log.Info("🗝️  SSH identity file", "path", cfg.SSHIdentityFile)
-	}
-	log.Info("🔄 Require reboot verification", "enabled", !cfg.AllowUncordonWithoutReboot)
-	if cfg.AllNodes {
-		log.Info("🌐 Processing all nodes", "exclude_control_plane", cfg.ExcludeControlPlane)
-	}
-	if cfg.DryRun {
-		log.Info("🧪 DRY-RUN mode enabled - no actual changes will be made")
-	}
-}
-
-func processNode(cfg *config.Config, kc *kube.Client, ssh *sshpkg.Runner, nodeName string) error {
-	ctx := context.Background()
-	log.Info("⏳ Starting node restart process", "node", nodeName)
-	nd, err := kc.GetNode(ctx, nodeName)
-	if err != nil {
-		return err
-	}
-
-	if !nd.Spec.Unschedulable {
-		if cfg.DryRun {
-			log.Info("🧪 DRY-RUN: Would cordon node", "node", nodeName)
-		} else if err := kc.Cordon(ctx, nodeName); err != nil {
-			return fmt.Errorf("cordon: %w", err)
-		}
-		log.Info("✅ Node cordoned - scheduling disabled", "node", nodeName)
-	} else {
-		log.Info("✅ Node already cordoned", "node", nodeName)
-	}
-
-	log.Info("⏳ Starting pod eviction process", "node", nodeName)
-	if err := kc.EvictPods(ctx, nodeName, time.Duration(cfg.PollIntervalSeconds)*time.Second, 10*time.Minute, cfg.DryRun); err != nil {
-		return fmt.Errorf("evict: %w", err)
-	}
-	log.Info("✅ Pod eviction completed successfully", "node", nodeName)
-
-	bootBefore := nd.Status.NodeInfo.BootID
-
-	log.Info("🔄 Initiating system reboot", "node", nodeName)
-	sshHost := buildSSHHost(cfg, nodeName)
-	if err := ssh.Run(sshHost, cfg.RebootCmd, log.Infof); err != nil {
-		log.Warn("⚠️ SSH reboot command failed", "node", nodeName, "error", err)
-	} else {
-		log.Info("✅ Reboot command sent successfully", "node", nodeName)
-	}
-
-	if !cfg.DryRun {
-		if bootBefore != "" {
-			log.Info("⏳ Waiting for node reboot", "node", nodeName, "timeout_seconds", cfg.TimeoutBootIDSeconds)
-			changed := kc.WaitForBootIDChange(ctx, nodeName, bootBefore, time.Duration(cfg.TimeoutBootIDSeconds)*time.Second, time.Duration(cfg.PollIntervalSeconds)*time.Second)
-			if !changed {
-				if !cfg.AllowUncordonWithoutReboot {
-					return fmt.Errorf("❌ Boot ID unchanged - reboot may have failed")
-				}
-				log.Warn("⚠️ Boot ID unchanged, but proceeding due to flag", "node", nodeName, "flag", "allow-uncordon-without-reboot")
-			} else {
-				log.Info("✅ Reboot confirmed", "node", nodeName)
-			}
-		}
-
-		log.Info("⏳ Waiting for node to become ready", "node", nodeName, "timeout_seconds", cfg.TimeoutReadySeconds)
-		if !kc.WaitForCondition(ctx, nodeName, kube.IsNodeReady, time.Duration(cfg.TimeoutReadySeconds)*time.Second, time.Duration(cfg.PollIntervalSeconds)*time.Second) {
-			return fmt.Errorf("❌ Node failed to become ready within timeout")
-		}
-		log.Info("✅ Node is ready", "node", nodeName)
-	} else {
-		log.Info("🧪 DRY-RUN: Skipping wait phases", "node", nodeName, "phases", "boot ID, ready")
-	}
-
-	// Uncordon
-	if cfg.DryRun {
-		log.Info("🧪 DRY-RUN: Would uncordon node", "node", nodeName)
-	} else if err := kc.Uncordon(ctx, nodeName); err != nil {
-		return fmt.Errorf("uncordon: %w", err)
-	}
-	log.Info("🎉 Node restart process completed successfully", "node", nodeName)
-	return nil
-}
-
 func buildSSHHost(cfg *config.Config, node string) string {
-	host := fmt.Sprintf(cfg.SSHHostTemplate, node)
-	if cfg.SSHUser != "" && !strings.Contains(host, "@") {
-		host = cfg.SSHUser + "@" + host
+	s := cfg.ForNode(node)
+	host := s.SSHHost
+	if host == "" {
+		host = fmt.Sprintf(s.SSHHostTemplate, node)
+	}
+	if s.SSHUser != "" && !strings.Contains(host, "@") {
+		host = s.SSHUser + "@" + host
 	}
 	return host
 }
 
-func readNodesFile(path string) ([]string, error) {
+// readNodesFile reads node names from path, one per line. Lines may carry
+// per-node overrides after the name (see config.ParseNodeLine); the returned
+// map only holds entries for nodes that had any.
+func readNodesFile(path string) ([]string, map[string]config.NodeOverride, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer func() {
 		if closeErr := f.Close(); closeErr != nil {
@@ -238,13 +196,23 @@ func readNodesFile(path string) ([]string, error) {
 		}
 	}()
 	var nodes []string
+	overrides := map[string]config.NodeOverride{}
 	sc := bufio.NewScanner(f)
+	lineNo := 0
 	for sc.Scan() {
+		lineNo++
 		line := strings.TrimSpace(sc.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		nodes = append(nodes, line)
+		name, o, err := config.ParseNodeLine(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		nodes = append(nodes, name)
+		if o != nil {
+			overrides[name] = *o
+		}
 	}
-	return nodes, sc.Err()
+	return nodes, overrides, sc.Err()
 }
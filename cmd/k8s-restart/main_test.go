@@ -6,6 +6,14 @@ import (
 	"testing"
 
 	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestBuildSSHHost(t *testing.T) {
@@ -92,7 +100,7 @@ node4
 	}
 
 	// Test reading the file
-	nodes, err := readNodesFile(tmpfile.Name())
+	nodes, _, err := readNodesFile(tmpfile.Name())
 	if err != nil {
 		t.Fatalf("readNodesFile() error = %v", err)
 	}
@@ -110,7 +118,7 @@ node4
 }
 
 func TestReadNodesFileNonExistent(t *testing.T) {
-	_, err := readNodesFile("/non/existent/file")
+	_, _, err := readNodesFile("/non/existent/file")
 	if err == nil {
 		t.Error("Expected error for non-existent file")
 	}
@@ -125,7 +133,7 @@ func TestReadNodesFileEmpty(t *testing.T) {
 	defer os.Remove(tmpfile.Name())
 	tmpfile.Close()
 
-	nodes, err := readNodesFile(tmpfile.Name())
+	nodes, _, err := readNodesFile(tmpfile.Name())
 	if err != nil {
 		t.Fatalf("readNodesFile() error = %v", err)
 	}
@@ -135,6 +143,76 @@ func TestReadNodesFileEmpty(t *testing.T) {
 	}
 }
 
+func TestReadNodesFileOverrides(t *testing.T) {
+	path := createTempNodesFile(t, `bm-01 host=10.0.0.5 user=admin reboot-cmd="sudo shutdown -r now" timeout-ready=600
+vm-01 reboot-method=none timeout-bootid=900 post-reboot-cmd=/opt/smoke-test.sh
+plain-01
+`)
+	defer os.Remove(path)
+
+	nodes, overrides, err := readNodesFile(path)
+	if err != nil {
+		t.Fatalf("readNodesFile() error = %v", err)
+	}
+	if strings.Join(nodes, ",") != "bm-01,vm-01,plain-01" {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if _, ok := overrides["plain-01"]; ok {
+		t.Error("plain-01 should have no overrides")
+	}
+
+	cfg := &config.Config{
+		SSHHostTemplate:      "%s.example.com",
+		SSHUser:              "ubuntu",
+		RebootCmd:            config.DefaultRebootCmd,
+		RebootMethod:         config.RebootMethodSSH,
+		TimeoutReadySeconds:  config.DefaultReadyTimeout,
+		TimeoutBootIDSeconds: config.DefaultBootIDTimeout,
+		PreRebootCmd:         "sudo systemctl stop gpu-manager",
+		NodeOverrides:        overrides,
+	}
+
+	bm := cfg.ForNode("bm-01")
+	if bm.RebootCmd != "sudo shutdown -r now" || bm.TimeoutReadySeconds != 600 || bm.TimeoutBootIDSeconds != config.DefaultBootIDTimeout {
+		t.Errorf("unexpected settings for bm-01: %+v", bm)
+	}
+	if got := buildSSHHost(cfg, "bm-01"); got != "admin@10.0.0.5" {
+		t.Errorf("buildSSHHost(bm-01) = %q, want admin@10.0.0.5", got)
+	}
+
+	vm := cfg.ForNode("vm-01")
+	if vm.RebootMethod != config.RebootMethodNone || vm.TimeoutBootIDSeconds != 900 || vm.RebootCmd != config.DefaultRebootCmd ||
+		vm.PostRebootCmd != "/opt/smoke-test.sh" || vm.PreRebootCmd != cfg.PreRebootCmd {
+		t.Errorf("unexpected settings for vm-01: %+v", vm)
+	}
+	if got := buildSSHHost(cfg, "plain-01"); got != "ubuntu@plain-01.example.com" {
+		t.Errorf("buildSSHHost(plain-01) = %q, want ubuntu@plain-01.example.com", got)
+	}
+}
+
+func TestReadNodesFileInvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown key", content: "node1 colour=blue\n"},
+		{name: "missing value separator", content: "node1 host\n"},
+		{name: "bad timeout", content: "node1 timeout-ready=soon\n"},
+		{name: "bad reboot method", content: "node1 reboot-method=ipmi\n"},
+		{name: "unterminated quote", content: "node1 reboot-cmd=\"sudo reboot\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := createTempNodesFile(t, tt.content)
+			defer os.Remove(path)
+			if _, _, err := readNodesFile(path); err == nil {
+				t.Error("Expected error for invalid overrides")
+			}
+		})
+	}
+}
+
 func TestProcessNodeConfigurationFileReading(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -176,7 +254,7 @@ func TestProcessNodeConfigurationFileReading(t *testing.T) {
 
 			// We can't easily test with real kube client, so test only file reading part
 			if tt.cfg.File != "" {
-				fileNodes, err := readNodesFile(tt.cfg.File)
+				fileNodes, _, err := readNodesFile(tt.cfg.File)
 				if err != nil && !tt.expectError {
 					t.Errorf("Unexpected error reading file: %v", err)
 				}
@@ -222,7 +300,7 @@ func TestFilterExcludedNodes(t *testing.T) {
 			excludeNodes:  []string{"node1", "node2"},
 			expectedNodes: []string{},
 			expectError:   true,
-			errorMsg:      "All nodes were excluded",
+			errorMsg:      "all nodes were excluded",
 		},
 		{
 			name:          "exclude non-existent node",
@@ -280,3 +358,37 @@ func createTempNodesFile(t *testing.T, content string) string {
 	}
 	return tmpfile.Name()
 }
+
+// newFakeClient returns a kube.Client backed by a fake clientset holding objs.
+func newFakeClient(objs ...runtime.Object) *kube.Client {
+	return &kube.Client{CS: fake.NewSimpleClientset(objs...)}
+}
+
+// newEvictingFakeClient is newFakeClient where an eviction deletes the pod,
+// as the API server does once the pod has terminated.
+func newEvictingFakeClient(objs ...runtime.Object) *kube.Client {
+	cs := fake.NewSimpleClientset(objs...)
+	cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		ev := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		return true, nil, cs.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), action.GetNamespace(), ev.Name)
+	})
+	return &kube.Client{CS: cs}
+}
+
+func testNode(name string, unschedulable bool) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+func newPlanCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plan [flags] [NODE_NAMES...]",
+		Short: "Resolve the target nodes and print the ordered plan without changing anything",
+		Example: `  # Show the batches a rolling reboot of all workers would use
+  kubectl reboot plan --all --exclude-control-plane --batch-size 3`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.Complete(args); err != nil {
+				return err
+			}
+			kclient, err := newKubeClient(cfg)
+			if err != nil {
+				return err
+			}
+			return runPlan(cfg, kclient, cmd.OutOrStdout())
+		},
+	}
+	cfg.AddTargetFlags(cmd.Flags())
+	cfg.AddBatchFlags(cmd.Flags())
+	cfg.AddRebootFlags(cmd.Flags())
+	registerNodeCompletions(cmd, cfg)
+	return cmd
+}
+
+func runPlan(cfg *config.Config, kclient *kube.Client, out io.Writer) error {
+	if err := resolveTargets(cfg, kclient, false); err != nil {
+		return err
+	}
+	printPlan(cfg, out, evictablePods(cfg, kclient))
+	return nil
+}
+
+// evictablePods counts the pods a drain would evict from each target node.
+// Nodes whose pods cannot be listed are left out.
+func evictablePods(cfg *config.Config, kclient *kube.Client) map[string]int {
+	counts := make(map[string]int, len(cfg.Nodes))
+	for _, node := range cfg.Nodes {
+		n, err := kclient.CountEvictablePods(context.Background(), node)
+		if err != nil {
+			log.Warn("Failed to list pods", "node", node, "error", err)
+			continue
+		}
+		counts[node] = n
+	}
+	return counts
+}
+
+// printPlan writes the batches, the per-node reboot settings and the number
+// of pods to evict in the order the reboot command would process them.
+func printPlan(cfg *config.Config, out io.Writer, pods map[string]int) {
+	batches := cfg.Batches()
+	fmt.Fprintf(out, "Plan: %d node(s) in %d batch(es) of up to %d\n", len(cfg.Nodes), len(batches), max(cfg.BatchSize, 1))
+	if canary := cfg.CanaryNodes(); len(canary) > 0 && len(canary) < len(cfg.Nodes) {
+		fmt.Fprintf(out, "Canary: %s, soaked for %s before the rest\n", strings.Join(canary, ","), cfg.Soak)
+	}
+	fmt.Fprintln(out)
+
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BATCH\tNODE\tMETHOD\tTARGET\tCOMMAND\tPODS")
+	for i, batch := range batches {
+		for _, node := range batch {
+			s := cfg.ForNode(node)
+			target, command := "-", "-"
+			if s.RebootMethod == config.RebootMethodSSH {
+				target, command = buildSSHHost(cfg, node), s.RebootCmd
+			}
+			count := "?"
+			if n, ok := pods[node]; ok {
+				count = strconv.Itoa(n)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, node, s.RebootMethod, target, command, count)
+		}
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []string
+		batchSize int
+		canary    int
+		expected  [][]string
+	}{
+		{
+			name:      "one per batch",
+			nodes:     []string{"node1", "node2", "node3"},
+			batchSize: 1,
+			expected:  [][]string{{"node1"}, {"node2"}, {"node3"}},
+		},
+		{
+			name:      "uneven last batch",
+			nodes:     []string{"node1", "node2", "node3"},
+			batchSize: 2,
+			expected:  [][]string{{"node1", "node2"}, {"node3"}},
+		},
+		{
+			name:      "unset batch size",
+			nodes:     []string{"node1", "node2"},
+			batchSize: 0,
+			expected:  [][]string{{"node1"}, {"node2"}},
+		},
+		{
+			name:      "no nodes",
+			nodes:     nil,
+			batchSize: 3,
+			expected:  nil,
+		},
+		{
+			name:      "canary split on its own",
+			nodes:     []string{"node1", "node2", "node3", "node4"},
+			batchSize: 2,
+			canary:    1,
+			expected:  [][]string{{"node1"}, {"node2", "node3"}, {"node4"}},
+		},
+		{
+			name:      "canary larger than batch",
+			nodes:     []string{"node1", "node2", "node3", "node4"},
+			batchSize: 2,
+			canary:    3,
+			expected:  [][]string{{"node1", "node2"}, {"node3"}, {"node4"}},
+		},
+		{
+			name:      "canary covers every node",
+			nodes:     []string{"node1", "node2"},
+			batchSize: 1,
+			canary:    5,
+			expected:  [][]string{{"node1"}, {"node2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{Nodes: tt.nodes, BatchSize: tt.batchSize, Canary: tt.canary}
+			got := cfg.Batches()
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Expected %d batches, got %d (%v)", len(tt.expected), len(got), got)
+			}
+			for i := range got {
+				if strings.Join(got[i], ",") != strings.Join(tt.expected[i], ",") {
+					t.Errorf("batch %d = %v, want %v", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunPlan(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}}
+	kclient := newFakeClient(testNode("node1", false), testNode("node2", false), testNode("node3", false), pod)
+	cfg := &config.Config{
+		AllNodes:        true,
+		ExcludeNodes:    []string{"node2"},
+		BatchSize:       1,
+		SSHHostTemplate: "%s.example.com",
+		SSHUser:         "ubuntu",
+		RebootCmd:       config.DefaultRebootCmd,
+		RebootMethod:    config.RebootMethodSSH,
+		NodeOverrides: map[string]config.NodeOverride{
+			"node3": {RebootMethod: config.RebootMethodNone},
+		},
+	}
+
+	var out bytes.Buffer
+	if err := runPlan(cfg, kclient, &out); err != nil {
+		t.Fatalf("runPlan() error = %v", err)
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "Plan: 2 node(s) in 2 batch(es) of up to 1") {
+		t.Errorf("missing plan summary in output:\n%s", got)
+	}
+	if strings.Contains(got, "node2") {
+		t.Errorf("excluded node2 should not be in the plan:\n%s", got)
+	}
+	if !strings.Contains(got, "ubuntu@node1.example.com") {
+		t.Errorf("missing SSH target for node1:\n%s", got)
+	}
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	for _, line := range lines {
+		if f := strings.Fields(line); len(f) > 1 && f[1] == "node1" && f[len(f)-1] != "1" {
+			t.Errorf("expected 1 pod to evict from node1, got %q", line)
+		}
+	}
+	last := strings.Fields(lines[len(lines)-1])
+	if len(last) < 3 || last[0] != "2" || last[1] != "node3" || last[2] != config.RebootMethodNone {
+		t.Errorf("expected node3 in batch 2 with method none, got %q", lines[len(lines)-1])
+	}
+}
+
+func TestRunPlanNoNodes(t *testing.T) {
+	cfg := &config.Config{BatchSize: 1}
+	if err := runPlan(cfg, newFakeClient(), &bytes.Buffer{}); err == nil {
+		t.Error("Expected error when no nodes are selected")
+	}
+}
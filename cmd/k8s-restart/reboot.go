@@ -0,0 +1,1191 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/alertmanager"
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/dashboard"
+	"github.com/ayetkin/kubectl-reboot/internal/hooks"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/metrics"
+	"github.com/ayetkin/kubectl-reboot/internal/notify"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	sshpkg "github.com/ayetkin/kubectl-reboot/internal/ssh"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const rebootExample = `  # Restart specific nodes
+  kubectl reboot node1 node2
+
+  # Restart all worker nodes (dry-run)
+  kubectl reboot --all --exclude-control-plane --dry-run
+
+  # Restart nodes from file, two at a time
+  kubectl reboot -f nodes.txt --batch-size 2
+
+  # Nodes file lines may carry per-node overrides
+  #   bm-01 host=10.0.0.5 user=admin reboot-cmd="sudo shutdown -r now"
+  #   vm-01 reboot-method=none timeout-bootid=900
+
+  # Custom SSH settings
+  kubectl reboot -u myuser -i ~/.ssh/mykey node1
+
+  # Use another context, impersonating a service account
+  kubectl reboot --context prod --as system:serviceaccount:ops:rebooter node1`
+
+// writeReport finishes the run report and writes it to stdout for -o and to
+// the --report file, if requested.
+func (r *rollout) writeReport() error {
+	r.report.Finish()
+	if r.cfg.Output != "" {
+		if err := r.report.Write(os.Stdout, r.cfg.Output); err != nil {
+			return err
+		}
+	}
+	if r.cfg.ReportFile != "" {
+		if err := r.report.WriteFile(r.cfg.ReportFile, r.cfg.Output); err != nil {
+			return err
+		}
+		log.Info("Run report written", "path", r.cfg.ReportFile)
+	}
+	return nil
+}
+
+// startMetrics registers the Prometheus metrics on the run report when
+// --metrics-addr or --metrics-textfile is set. The returned function stops
+// the listener and writes the final textfile.
+func (r *rollout) startMetrics() (func(), error) {
+	if r.cfg.MetricsAddr == "" && r.cfg.MetricsTextfile == "" {
+		return func() {}, nil
+	}
+	m := metrics.New(r.cfg.MetricsTextfile)
+	m.SetTargetNodes(len(r.cfg.Nodes))
+	r.report.AddObserver(m)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if r.cfg.MetricsAddr != "" {
+		addr, err := m.Serve(ctx, r.cfg.MetricsAddr)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("metrics listener: %w", err)
+		}
+		log.Info("Serving metrics", "address", addr.String())
+	}
+	if err := m.WriteTextfile(); err != nil {
+		log.Warn("Failed to write metrics textfile", "path", r.cfg.MetricsTextfile, "error", err)
+	}
+	return func() {
+		cancel()
+		if err := m.WriteTextfile(); err != nil {
+			log.Warn("Failed to write metrics textfile", "path", r.cfg.MetricsTextfile, "error", err)
+		}
+	}, nil
+}
+
+// acquireLock takes the cluster-wide Lease so that no other run can start
+// against the cluster until the returned function releases it. Dry runs
+// change nothing and skip it.
+func (r *rollout) acquireLock() (func(), error) {
+	if r.cfg.DryRun {
+		log.Info("Run lock skipped (dry-run)")
+		return func() {}, nil
+	}
+	ns := r.cfg.LockNamespace
+	if ns == "" {
+		ns = kube.DefaultLockNamespace
+	}
+	lock, err := r.kc.AcquireLock(context.Background(), ns, r.identity, r.runID, kube.DefaultLockDuration, r.cfg.ForceLock)
+	if err != nil {
+		return nil, fmt.Errorf("acquire run lock: %w", err)
+	}
+	if r.cfg.ForceLock {
+		log.Warn("Run lock taken with --force-lock", "lease", ns+"/"+kube.LockName)
+	}
+	log.Info("Run lock acquired", "lease", ns+"/"+kube.LockName)
+	lock.KeepAlive(kube.DefaultLockDuration / 3)
+	r.lock = lock
+
+	return func() {
+		if err := lock.Release(context.Background()); err != nil {
+			log.Warn("Failed to release run lock", "lease", ns+"/"+kube.LockName, "error", err)
+		}
+	}, nil
+}
+
+// lockErr returns why the run lock was lost, or nil while it is held or
+// when the run has none.
+func (r *rollout) lockErr() error {
+	if r.lock == nil {
+		return nil
+	}
+	return r.lock.Err()
+}
+
+// newRunID returns a short random identifier for this run.
+func newRunID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// newNotifier returns a Notifier for the configured webhooks, registered on
+// the run report for per-node notifications, or nil if none are configured.
+func (r *rollout) newNotifier() *notify.Notifier {
+	var hooks []notify.Webhook
+	for _, u := range r.cfg.Webhooks {
+		hooks = append(hooks, notify.Webhook{URL: u, Format: notify.FormatGeneric})
+	}
+	for _, u := range r.cfg.SlackWebhooks {
+		hooks = append(hooks, notify.Webhook{URL: u, Format: notify.FormatSlack})
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+	n := &notify.Notifier{
+		Webhooks: hooks,
+		RunID:    r.runID,
+		Cluster:  currentContext(r.cfg),
+		Operator: r.identity,
+		DryRun:   r.cfg.DryRun,
+		Retries:  r.cfg.WebhookRetries,
+		Backoff:  time.Second,
+		Logger:   log.Default(),
+	}
+	r.report.AddObserver(n)
+	return n
+}
+
+// currentContext names the kubeconfig context in use, if any.
+func currentContext(cfg *config.Config) string {
+	if cfg.KubeFlags == nil {
+		return ""
+	}
+	if cfg.KubeFlags.Context != nil && *cfg.KubeFlags.Context != "" {
+		return *cfg.KubeFlags.Context
+	}
+	raw, err := cfg.KubeFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return ""
+	}
+	return raw.CurrentContext
+}
+
+var (
+	// errNodesFailed is returned once the per-node failures have been logged.
+	errNodesFailed = errors.New("one or more nodes failed")
+	// errRunAborted is returned when --max-failures stopped the run before
+	// every node was attempted.
+	errRunAborted = errors.New("run aborted after too many failures")
+	// errInterrupted is returned when SIGINT or SIGTERM stopped the run.
+	errInterrupted = errors.New("run interrupted")
+	// errNodeStopped is returned by processNode for a node left for a later
+	// run (see rollout.resumeStopped).
+	errNodeStopped = errors.New("stopped before the node was done")
+)
+
+func newRebootCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "reboot [flags] [NODE_NAMES...]",
+		Short:   "Cordon, drain, reboot, verify and uncordon nodes",
+		Example: rebootExample,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRebootCommand(cfg, args)
+		},
+	}
+	addRebootFlags(cmd, cfg)
+	return cmd
+}
+
+// addRebootFlags registers the flags of a reboot run on cmd. Both the root
+// command and the reboot subcommand run reboots, so they share this set.
+func addRebootFlags(cmd *cobra.Command, cfg *config.Config) {
+	cfg.AddTargetFlags(cmd.Flags())
+	cfg.AddBatchFlags(cmd.Flags())
+	cfg.AddRunFlags(cmd.Flags())
+	cfg.AddRebootFlags(cmd.Flags())
+	cfg.AddReportFlags(cmd.Flags())
+	cfg.AddMetricsFlags(cmd.Flags())
+	cfg.AddNotifyFlags(cmd.Flags())
+	cfg.AddLockFlags(cmd.Flags())
+	cfg.AddSilenceFlags(cmd.Flags())
+	cfg.AddConfirmFlags(cmd.Flags())
+	registerNodeCompletions(cmd, cfg)
+}
+
+func runRebootCommand(cfg *config.Config, args []string) error {
+	if err := cfg.Complete(args); err != nil {
+		return err
+	}
+	kclient, err := newKubeClient(cfg)
+	if err != nil {
+		return err
+	}
+	if err := resolveTargets(cfg, kclient, false); err != nil {
+		return err
+	}
+	if cfg.SkipRebootedWithin > 0 {
+		if err := skipRecentlyRebooted(cfg, kclient, time.Now()); err != nil {
+			return err
+		}
+		if len(cfg.Nodes) == 0 {
+			log.Info("All target nodes were restarted recently, nothing to do", "skip_rebooted_within", cfg.SkipRebootedWithin)
+			return nil
+		}
+	}
+
+	// Log configuration and start operations
+	logConfiguration(cfg)
+	terminal := isTerminal(os.Stdin) && isTerminal(os.Stderr)
+	if cfg.Interactive && !terminal {
+		return fmt.Errorf("--interactive needs a terminal on stdin and stderr")
+	}
+	if err := confirmRun(cfg, kclient, os.Stdin, os.Stderr, terminal); err != nil {
+		return err
+	}
+	waitForWindow(cfg)
+
+	r := newRollout(cfg, kclient, operatorIdentity())
+	if cfg.Interactive {
+		r.step = newStepper(os.Stdin, os.Stderr)
+	}
+	if useDashboard(cfg, isTerminal(os.Stdout)) {
+		if r.dash, err = r.newDashboard(isTerminal(os.Stderr)); err != nil {
+			log.Warn("Dashboard disabled", "error", err)
+		}
+	}
+	log.Info("Run started", "run_id", r.runID, "operator", r.identity)
+
+	// SIGINT and SIGTERM cancel the run: the nodes in flight are cleaned up
+	// as for a failure and the report, notifications and lock release still
+	// happen. A second signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	r.ctx = ctx
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+			log.Warn("Interrupted, stopping after the nodes in flight; interrupt again to exit now")
+		case <-done:
+		}
+	}()
+
+	release, err := r.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer release()
+	stopMetrics, err := r.startMetrics()
+	if err != nil {
+		return err
+	}
+	defer stopMetrics()
+	r.notifier = r.newNotifier()
+
+	if cfg.InitialDelay > 0 {
+		log.Info("Initial wait before starting operations", "delay", cfg.InitialDelay)
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.InitialDelay):
+		}
+	}
+
+	if r.notifier != nil {
+		r.notifier.RunStarted(cfg.Nodes)
+	}
+	r.startDashboard()
+	failures, notAttempted := r.runBatches()
+	r.stopDashboard()
+	if err := r.writeReport(); err != nil {
+		log.Error("Failed to write run report", "error", err)
+	}
+	aborted := len(notAttempted) > 0 && !r.report.WindowClosed
+	if r.notifier != nil {
+		r.notifier.RunFinished(r.report.Totals, failures, aborted)
+	}
+	if ctx.Err() != nil {
+		log.Error("Run interrupted", "failed_count", len(failures), "failed_nodes", strings.Join(failures, ","), "not_attempted", strings.Join(notAttempted, ","))
+		return errInterrupted
+	}
+	if aborted {
+		log.Error("Run aborted", "failed_count", len(failures), "failed_nodes", strings.Join(failures, ","), "not_attempted", strings.Join(notAttempted, ","))
+		return errRunAborted
+	}
+	if len(failures) > 0 {
+		log.Error("Operation failed", "failed_count", len(failures), "failed_nodes", strings.Join(failures, ","))
+		return errNodesFailed
+	}
+	if len(notAttempted) > 0 {
+		log.Info("Run stopped at the end of the maintenance window, rerun with --skip-rebooted-within to resume", "not_attempted", strings.Join(notAttempted, ","))
+		return nil
+	}
+	log.Info("All nodes processed successfully")
+	return nil
+}
+
+// newRollout prepares a run of cfg with a fresh run ID and report.
+func newRollout(cfg *config.Config, kclient *kube.Client, identity string) *rollout {
+	r := &rollout{
+		ctx:      context.Background(),
+		cfg:      cfg,
+		kc:       kclient,
+		ssh:      &sshpkg.Runner{DryRun: cfg.DryRun, Opts: cfg.SSHOpts, Key: cfg.SSHIdentityFile},
+		hooks:    &hooks.Runner{Hooks: cfg.Hooks, Timeout: time.Duration(cfg.HookTimeoutSeconds) * time.Second, DryRun: cfg.DryRun, Logger: log.Default()},
+		report:   report.New(cfg.DryRun),
+		identity: identity,
+		runID:    newRunID(),
+	}
+	r.report.RunID = r.runID
+	if cfg.AlertmanagerURL != "" {
+		r.silencer = &alertmanager.Client{
+			URL:       cfg.AlertmanagerURL,
+			Matchers:  cfg.SilenceMatchers,
+			Duration:  time.Duration(cfg.SilenceDurationSeconds) * time.Second,
+			CreatedBy: identity,
+		}
+	}
+	return r
+}
+
+// runBatches processes the target nodes batch by batch, restarting the nodes
+// of a batch concurrently, and returns the names of the nodes that failed in
+// target order. Once --max-failures nodes have failed, when the --canary
+// nodes turn out unhealthy, when the run lock is lost or the run cancelled,
+// or when the --window would close before the next batch is expected to
+// finish, no further batch is started; the nodes never attempted are
+// returned as well. With --interactive the operator is asked how to go on
+// after each batch.
+func (r *rollout) runBatches() (failures, notAttempted []string) {
+	batches := r.cfg.Batches()
+	canary := r.cfg.CanaryNodes()
+	canaryBatches := (len(canary) + max(r.cfg.BatchSize, 1) - 1) / max(r.cfg.BatchSize, 1)
+	var took []time.Duration
+	for i := 0; i < len(batches); i++ {
+		batch := batches[i]
+		if r.cfg.MaxFailures > 0 && len(failures) >= r.cfg.MaxFailures {
+			for _, rest := range batches[i:] {
+				notAttempted = append(notAttempted, rest...)
+			}
+			log.Error("Failure threshold reached, no further nodes will be started", "failed_count", len(failures), "max_failures", r.cfg.MaxFailures, "not_attempted", strings.Join(notAttempted, ","))
+			r.report.Abort(notAttempted)
+			return failures, notAttempted
+		}
+		if err := r.lockErr(); err != nil {
+			for _, rest := range batches[i:] {
+				notAttempted = append(notAttempted, rest...)
+			}
+			log.Error("Run lock lost, no further nodes will be started", "error", err, "not_attempted", strings.Join(notAttempted, ","))
+			r.report.Abort(notAttempted)
+			return failures, notAttempted
+		}
+		if err := r.ctx.Err(); err != nil {
+			for _, rest := range batches[i:] {
+				notAttempted = append(notAttempted, rest...)
+			}
+			log.Error("Run cancelled, no further nodes will be started", "error", err, "not_attempted", strings.Join(notAttempted, ","))
+			r.report.Abort(notAttempted)
+			return failures, notAttempted
+		}
+		if estimate := r.batchEstimate(took); !r.windowAllows(time.Now(), estimate) {
+			for _, rest := range batches[i:] {
+				notAttempted = append(notAttempted, rest...)
+			}
+			log.Warn("Not enough of the maintenance window left for another batch, stopping", "window", r.cfg.Window, "remaining", r.cfg.Window.Remaining(time.Now()).Round(time.Second), "estimate", estimate.Round(time.Second), "not_attempted", strings.Join(notAttempted, ","))
+			r.report.CloseWindow(notAttempted)
+			return failures, notAttempted
+		}
+		start := time.Now()
+		if len(batches) > 1 {
+			log.Info("Starting batch", "batch", i+1, "batches", len(batches), "nodes", strings.Join(batch, ","))
+		}
+		failed := r.runBatch(batch)
+		took = append(took, time.Since(start))
+
+		skipNext := false
+		if r.step != nil && (i+1 < len(batches) || len(failed) > 0) {
+			var next []string
+			if i+1 < len(batches) {
+				next = batches[i+1]
+			}
+			switch r.stepAfterBatch(i+1, len(batches), batch, &failed, next) {
+			case stepSkip:
+				skipNext = true
+			case stepAbort:
+				failures = append(failures, failed...)
+				for _, rest := range batches[i+1:] {
+					notAttempted = append(notAttempted, rest...)
+				}
+				log.Error("Run aborted by operator", "failed_count", len(failures), "not_attempted", strings.Join(notAttempted, ","))
+				r.report.Abort(notAttempted)
+				return failures, notAttempted
+			}
+		}
+		failures = append(failures, failed...)
+
+		// A soak cut short by cancellation is handled as the cancellation
+		// at the top of the loop, not as an unhealthy canary.
+		if i+1 == canaryBatches && i+1 < len(batches) {
+			if err := r.soakCanary(canary, failures); err != nil && r.ctx.Err() == nil {
+				for _, rest := range batches[i+1:] {
+					notAttempted = append(notAttempted, rest...)
+				}
+				log.Error("Canary unhealthy, no further nodes will be started", "error", err, "not_attempted", strings.Join(notAttempted, ","))
+				r.report.Abort(notAttempted)
+				return failures, notAttempted
+			}
+		}
+		if skipNext {
+			log.Warn("Batch skipped by operator", "batch", i+2, "nodes", strings.Join(batches[i+1], ","))
+			r.report.SkipNodes(batches[i+1])
+			i++
+		}
+	}
+	return failures, nil
+}
+
+// runBatch restarts nodes concurrently and returns those that failed, in
+// the given order. Nodes left for a later run are not failures.
+func (r *rollout) runBatch(nodes []string) []string {
+	failed := make([]bool, len(nodes))
+	var wg sync.WaitGroup
+	for j, node := range nodes {
+		wg.Add(1)
+		go func(j int, node string) {
+			defer wg.Done()
+			err := r.processNode(node)
+			switch {
+			case errors.Is(err, errNodeStopped):
+				log.Warn("Node processing stopped, left for the next run", "node", node, "error", err)
+			case err != nil:
+				log.Error("Node processing failed", "node", node, "error", err)
+				failed[j] = true
+			}
+		}(j, node)
+	}
+	wg.Wait()
+	var failures []string
+	for j, node := range nodes {
+		if failed[j] {
+			failures = append(failures, node)
+		}
+	}
+	return failures
+}
+
+// windowAllows reports whether a batch expected to take estimate may start
+// at now: without --window, in dry-run, or when the window stays open for at
+// least that long.
+func (r *rollout) windowAllows(now time.Time, estimate time.Duration) bool {
+	if r.cfg.Window == nil || r.cfg.DryRun {
+		return true
+	}
+	return r.cfg.Window.Remaining(now) >= estimate
+}
+
+// batchEstimate predicts how long the next batch takes: the average of the
+// batches completed so far, or --node-estimate before the first one.
+func (r *rollout) batchEstimate(took []time.Duration) time.Duration {
+	if len(took) == 0 {
+		return time.Duration(r.cfg.NodeEstimateSeconds) * time.Second
+	}
+	var total time.Duration
+	for _, d := range took {
+		total += d
+	}
+	return total / time.Duration(len(took))
+}
+
+// waitForWindow blocks until the --window is open. The run lock is taken
+// only afterwards, so that a run waiting for its window does not block
+// others.
+func waitForWindow(cfg *config.Config) {
+	if cfg.Window == nil {
+		return
+	}
+	now := time.Now()
+	opens, closes := cfg.Window.Next(now)
+	if !opens.After(now) {
+		log.Info("Inside maintenance window", "window", cfg.Window, "closes_at", closes.Format(time.RFC3339))
+		return
+	}
+	if cfg.DryRun {
+		log.Info("Maintenance window not open, not waiting (dry-run)", "window", cfg.Window, "opens_at", opens.Format(time.RFC3339))
+		return
+	}
+	log.Info("Waiting for maintenance window", "window", cfg.Window, "opens_at", opens.Format(time.RFC3339), "wait", time.Until(opens).Round(time.Second))
+	time.Sleep(time.Until(opens))
+}
+
+// skipRecentlyRebooted drops the target nodes whose last kubectl-reboot
+// restart is more recent than --skip-rebooted-within, so that rerunning an
+// interrupted run picks up where it stopped.
+func skipRecentlyRebooted(cfg *config.Config, kclient *kube.Client, now time.Time) error {
+	var keep, skipped []string
+	for _, name := range cfg.Nodes {
+		nd, err := kclient.GetNode(context.Background(), name)
+		if err != nil {
+			return fmt.Errorf("get node %s: %w", name, err)
+		}
+		last, err := time.Parse(time.RFC3339, nd.Annotations[kube.AnnotationLastRebootTime])
+		if err == nil && now.Sub(last) < cfg.SkipRebootedWithin {
+			skipped = append(skipped, name)
+			continue
+		}
+		keep = append(keep, name)
+	}
+	if len(skipped) > 0 {
+		log.Info("Skipping nodes restarted recently", "count", len(skipped), "nodes", strings.Join(skipped, ","), "skip_rebooted_within", cfg.SkipRebootedWithin)
+	}
+	cfg.Nodes = keep
+	return nil
+}
+
+func logConfiguration(cfg *config.Config) {
+	log.Info("Starting reboot operation")
+	log.Info("Target nodes", "count", len(cfg.Nodes), "nodes", strings.Join(cfg.Nodes, ","))
+	log.Info("Drain arguments", "args", cfg.DrainArgs)
+	log.Info("SSH options", "opts", cfg.SSHOpts)
+	if cfg.SSHIdentityFile != "" {
+		log.Info("SSH identity file", "path", cfg.SSHIdentityFile)
+	}
+	log.Info("Require reboot verification", "enabled", !cfg.AllowUncordonWithoutReboot)
+	if cfg.AllNodes {
+		log.Info("Processing all nodes", "exclude_control_plane", cfg.ExcludeControlPlane)
+	}
+	if cfg.BatchSize > 1 {
+		log.Info("Batch size", "nodes", cfg.BatchSize)
+	}
+	if canary := cfg.CanaryNodes(); len(canary) > 0 {
+		log.Info("Canary nodes", "nodes", strings.Join(canary, ","), "soak", cfg.Soak)
+	}
+	if cfg.DryRun {
+		log.Info("Dry-run mode enabled, no changes will be made")
+	}
+}
+
+// Reasons of the Events recorded on a node as it moves through the phases.
+const (
+	eventCordoned        = "Cordoned"
+	eventDrained         = "Drained"
+	eventRebootTriggered = "RebootTriggered"
+	eventRebootVerified  = "RebootVerified"
+	eventUncordoned      = "Uncordoned"
+	eventFailed          = "Failed"
+)
+
+// remoteRunner runs commands on the nodes; *sshpkg.Runner in production.
+type remoteRunner interface {
+	Run(logger *log.Logger, host, command string) error
+	Output(logger *log.Logger, host, command string, timeout time.Duration) (string, error)
+}
+
+// rollout carries the clients and run-wide state shared by every node of a
+// reboot run.
+type rollout struct {
+	// ctx cancels the nodes in flight; the controller sets it to its own.
+	ctx context.Context
+	// resumeStopped leaves a node stopped by cancelling ctx as it is, for
+	// a later run to pick up, instead of handling it as failed. The
+	// controller sets it; the CLI cleans such nodes up as failures.
+	resumeStopped bool
+	cfg           *config.Config
+	kc            *kube.Client
+	ssh           remoteRunner
+	hooks         *hooks.Runner
+	silencer      *alertmanager.Client
+	report        *report.Report
+	identity      string
+	runID         string
+	notifier      *notify.Notifier
+	// lock is the run lock, held while batches run.
+	lock *kube.Lock
+	// step asks the operator how to go on after each batch (--interactive).
+	step *stepper
+	// dash shows the live progress dashboard while batches run.
+	dash *dashboard.Dashboard
+	// dashLog receives the logs while dash is shown, if stderr is the
+	// terminal it is drawn on.
+	dashLog *os.File
+}
+
+func (r *rollout) processNode(nodeName string) (err error) {
+	cfg, kc := r.cfg, r.kc
+	nr := r.report.Node(nodeName)
+	logger := log.With("node", nodeName)
+	ctx := r.ctx
+	// Cleanup still runs once ctx is cancelled.
+	cleanupCtx := context.WithoutCancel(ctx)
+
+	var nd *corev1.Node
+	defer func() {
+		if err != nil && r.resumeStopped && ctx.Err() != nil {
+			nr.Stop(err)
+			err = fmt.Errorf("%w: %v", errNodeStopped, err)
+			return
+		}
+		if err != nil && nd != nil {
+			r.applyFailurePolicy(cleanupCtx, nr, logger)
+			r.recordEvent(cleanupCtx, nd, corev1.EventTypeWarning, eventFailed, fmt.Sprintf("kubectl-reboot failed: %v", err))
+			r.runFailureHooks(cleanupCtx, nr, logger, err)
+		}
+		nr.Finish(err)
+	}()
+
+	logger.Info("Starting node restart")
+	nd, err = kc.GetNode(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	previouslyCordoned := nd.Spec.Unschedulable && nd.Annotations[kube.AnnotationCordonedBy] == ""
+	if previouslyCordoned {
+		nr.SetPreviouslyCordoned()
+	}
+
+	pods, err := kc.ListNodePods(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("list pods: %w", err)
+	}
+	if err := r.checkCapacity(ctx, nr, logger, pods); err != nil {
+		return err
+	}
+
+	if err := r.runHooks(ctx, nr, logger, hooks.BeforeCordon, report.PhaseCordon); err != nil {
+		return err
+	}
+
+	switch {
+	case !nd.Spec.Unschedulable:
+		p := nr.StartPhase(report.PhaseCordon)
+		if cfg.DryRun {
+			logger.Info("Cordon skipped (dry-run)", "phase", p.Name)
+		} else if err := kc.Cordon(ctx, nodeName, r.identity); err != nil {
+			return p.Fail(fmt.Errorf("cordon: %w", err))
+		}
+		p.Succeed()
+		r.recordEvent(ctx, nd, corev1.EventTypeNormal, eventCordoned, "Node cordoned by kubectl-reboot")
+		logger.Info("Node cordoned", "phase", p.Name, "duration", p.Duration())
+	case nd.Annotations[kube.AnnotationCordonedBy] != "":
+		// Left cordoned by an earlier, interrupted kubectl-reboot run.
+		nr.SkipPhase(report.PhaseCordon, "node already cordoned by kubectl-reboot")
+		logger.Info("Node already cordoned by kubectl-reboot", "phase", report.PhaseCordon, "cordoned_by", nd.Annotations[kube.AnnotationCordonedBy])
+	default:
+		nr.SkipPhase(report.PhaseCordon, "node already cordoned")
+		logger.Info("Node already cordoned by someone else, it will be left cordoned", "phase", report.PhaseCordon, "force_uncordon", cfg.ForceUncordon)
+	}
+
+	p := nr.StartPhase(report.PhaseDrain)
+	logger.Info("Starting pod eviction", "phase", p.Name)
+	eviction, err := kc.EvictPods(ctx, nodeName, pods, time.Duration(cfg.PollIntervalSeconds)*time.Second, 10*time.Minute, cfg.DryRun)
+	var evicted []string
+	if eviction != nil {
+		evicted = eviction.Evicted
+		nr.AddEvictedPods(eviction.Evicted, eviction.Retries)
+	}
+	if err != nil {
+		return p.Fail(fmt.Errorf("evict: %w", err))
+	}
+	p.Succeed()
+	r.recordEvent(ctx, nd, corev1.EventTypeNormal, eventDrained, fmt.Sprintf("Node drained by kubectl-reboot, %d pod(s) evicted", len(evicted)))
+	logger.Info("Pod eviction completed", "phase", p.Name, "duration", p.Duration(), "pods", len(evicted))
+	if err := r.runHooks(ctx, nr, logger, hooks.AfterDrain, report.PhaseDrain); err != nil {
+		return err
+	}
+
+	var workloads []kube.Workload
+	if cfg.WaitWorkloads {
+		if workloads, err = kc.WorkloadsOf(ctx, pods); err != nil {
+			return fmt.Errorf("resolve workloads: %w", err)
+		}
+	}
+	if err := r.waitForWorkloads(ctx, nr, logger, report.PhaseWaitWorkloads, workloads); err != nil {
+		return err
+	}
+
+	settings := cfg.ForNode(nodeName)
+	sshHost := buildSSHHost(cfg, nodeName)
+	if err := r.runRemoteCmd(nr, logger, report.PhasePreReboot, sshHost, settings.PreRebootCmd); err != nil {
+		return err
+	}
+
+	silenceID, err := r.silenceAlerts(ctx, nr, logger)
+	if err != nil {
+		return err
+	}
+	// Expire the silence once the node is Ready again, or when its restart
+	// fails, so that a broken node still pages.
+	expireSilence := func() {
+		if silenceID != "" {
+			r.expireSilence(cleanupCtx, logger, silenceID)
+			silenceID = ""
+		}
+	}
+	defer expireSilence()
+
+	bootBefore := nd.Status.NodeInfo.BootID
+	nr.SetBootIDs(bootBefore, "")
+
+	if settings.RebootMethod == config.RebootMethodNone {
+		nr.SkipPhase(report.PhaseReboot, "reboot method none - waiting for an out-of-band reboot")
+		logger.Info("Reboot command skipped, waiting for an out-of-band reboot", "phase", report.PhaseReboot, "reboot_method", settings.RebootMethod)
+	} else {
+		p := nr.StartPhase(report.PhaseReboot)
+		logger.Info("Initiating reboot", "phase", p.Name)
+		if err := r.ssh.Run(logger.With("phase", p.Name), sshHost, settings.RebootCmd); err != nil {
+			_ = p.Fail(err)
+			logger.Warn("SSH reboot command failed", "phase", p.Name, "duration", p.Duration(), "error", err)
+		} else {
+			p.Succeed()
+			r.recordEvent(ctx, nd, corev1.EventTypeNormal, eventRebootTriggered, fmt.Sprintf("Reboot triggered by kubectl-reboot via %s", settings.RebootMethod))
+			logger.Info("Reboot command sent", "phase", p.Name, "duration", p.Duration())
+		}
+	}
+
+	if !cfg.DryRun {
+		if bootBefore != "" {
+			p := nr.StartPhase(report.PhaseWaitBootID)
+			logger.Info("Waiting for boot ID change", "phase", p.Name, "timeout_seconds", settings.TimeoutBootIDSeconds)
+			changed := kc.WaitForBootIDChange(ctx, nodeName, bootBefore, time.Duration(settings.TimeoutBootIDSeconds)*time.Second, time.Duration(cfg.PollIntervalSeconds)*time.Second)
+			if !changed {
+				if err := ctx.Err(); err != nil {
+					return p.Fail(err)
+				}
+				if !cfg.AllowUncordonWithoutReboot {
+					return p.Fail(fmt.Errorf("boot ID unchanged - reboot may have failed"))
+				}
+				_ = p.Fail(fmt.Errorf("boot ID unchanged"))
+				logger.Warn("Boot ID unchanged, proceeding due to flag", "phase", p.Name, "duration", p.Duration(), "flag", "allow-uncordon-without-reboot")
+			} else {
+				p.Succeed()
+				logger.Info("Reboot confirmed", "phase", p.Name, "duration", p.Duration())
+			}
+		} else {
+			nr.SkipPhase(report.PhaseWaitBootID, "node reports no boot ID")
+		}
+
+		p := nr.StartPhase(report.PhaseWaitReady)
+		logger.Info("Waiting for node to become ready", "phase", p.Name, "timeout_seconds", settings.TimeoutReadySeconds)
+		if !kc.WaitForCondition(ctx, nodeName, kube.IsNodeReady, time.Duration(settings.TimeoutReadySeconds)*time.Second, time.Duration(cfg.PollIntervalSeconds)*time.Second) {
+			if err := ctx.Err(); err != nil {
+				return p.Fail(err)
+			}
+			return p.Fail(fmt.Errorf("node failed to become ready within timeout"))
+		}
+		p.Succeed()
+		logger.Info("Node is ready", "phase", p.Name, "duration", p.Duration())
+		expireSilence()
+		bootAfter := ""
+		if after, err := kc.GetNode(ctx, nodeName); err == nil {
+			bootAfter = after.Status.NodeInfo.BootID
+			nr.SetBootIDs("", bootAfter)
+		}
+		// Without a new boot ID (--allow-uncordon-without-reboot) the node
+		// is not recorded as rebooted.
+		if bootBefore == "" || bootAfter != bootBefore {
+			r.recordEvent(ctx, nd, corev1.EventTypeNormal, eventRebootVerified, fmt.Sprintf("Node rebooted and Ready, boot ID %s -> %s", valueOr(bootBefore, "unknown"), valueOr(bootAfter, "unknown")))
+			r.annotateReboot(ctx, nodeName, bootBefore, bootAfter)
+		}
+		if err := r.runHooks(ctx, nr, logger, hooks.AfterReboot, report.PhaseWaitReady); err != nil {
+			return err
+		}
+
+		if err := r.runRemoteCmd(nr, logger, report.PhasePostReboot, sshHost, settings.PostRebootCmd); err != nil {
+			return err
+		}
+		if err := r.runHealthGates(ctx, nr, logger); err != nil {
+			return err
+		}
+	} else {
+		nr.SkipPhase(report.PhaseWaitBootID, "dry-run")
+		nr.SkipPhase(report.PhaseWaitReady, "dry-run")
+		if r.hooks.Has(hooks.AfterReboot) {
+			nr.SkipPhase(report.HookPhase(string(hooks.AfterReboot)), "dry-run")
+		}
+		if settings.PostRebootCmd != "" {
+			nr.SkipPhase(report.PhasePostReboot, "dry-run")
+		}
+		if len(cfg.HealthGates) > 0 {
+			nr.SkipPhase(report.PhaseHealthGates, "dry-run")
+		}
+		logger.Info("Wait phases skipped (dry-run)", "phases", "wait-boot-id,wait-ready,post-reboot,health-gates")
+	}
+
+	// Uncordon
+	if r.keepCordoned(nr) {
+		nr.SkipPhase(report.PhaseUncordon, "node was cordoned before the run")
+		logger.Info("Node left cordoned as it was before the run", "phase", report.PhaseUncordon)
+	} else {
+		if err := r.runHooks(ctx, nr, logger, hooks.BeforeUncordon, report.PhaseUncordon); err != nil {
+			return err
+		}
+		p = nr.StartPhase(report.PhaseUncordon)
+		if cfg.DryRun {
+			logger.Info("Uncordon skipped (dry-run)", "phase", p.Name)
+		} else if err := kc.Uncordon(ctx, nodeName); err != nil {
+			return p.Fail(fmt.Errorf("uncordon: %w", err))
+		}
+		p.Succeed()
+		r.recordEvent(ctx, nd, corev1.EventTypeNormal, eventUncordoned, "Node uncordoned by kubectl-reboot")
+	}
+
+	if err := r.waitForWorkloads(ctx, nr, logger, report.PhaseSettle, workloads); err != nil {
+		return err
+	}
+	logger.Info("Node restart completed", "duration", time.Since(*nr.StartedAt).Round(time.Millisecond))
+	return nil
+}
+
+// waitForWorkloads records phase on the node and, with --wait-workloads,
+// waits until every workload owning an evicted pod is fully available again,
+// so that consecutive nodes do not stack disruptions.
+func (r *rollout) waitForWorkloads(ctx context.Context, nr *report.NodeReport, logger *log.Logger, phase report.Phase, workloads []kube.Workload) error {
+	if !r.cfg.WaitWorkloads {
+		return nil
+	}
+	if r.cfg.DryRun {
+		nr.SkipPhase(phase, "dry-run")
+		logger.Info("Workload wait skipped (dry-run)", "phase", phase, "workloads", len(workloads))
+		return nil
+	}
+	if len(workloads) == 0 {
+		nr.SkipPhase(phase, "no evicted pods owned by a Deployment, StatefulSet or ReplicaSet")
+		return nil
+	}
+	p := nr.StartPhase(phase)
+	logger.Info("Waiting for workloads to become available", "phase", p.Name, "workloads", len(workloads), "timeout_seconds", r.cfg.TimeoutWorkloadsSeconds)
+	err := r.kc.WaitForWorkloads(ctx, workloads, time.Duration(r.cfg.TimeoutWorkloadsSeconds)*time.Second, time.Duration(r.cfg.PollIntervalSeconds)*time.Second)
+	if err != nil {
+		return p.Fail(err)
+	}
+	p.Succeed()
+	logger.Info("Workloads available", "phase", p.Name, "duration", p.Duration(), "workloads", len(workloads))
+	return nil
+}
+
+// runHooks runs the --hook executables registered for event as a phase of
+// their own. A failing before-* hook fails the node; the failure of any other
+// hook is recorded and logged only.
+func (r *rollout) runHooks(ctx context.Context, nr *report.NodeReport, logger *log.Logger, event hooks.Event, phase report.Phase) error {
+	if !r.hooks.Has(event) {
+		return nil
+	}
+	p := nr.StartPhase(report.HookPhase(string(event)))
+	out, err := r.hooks.Run(ctx, r.hookPayload(nr, event, phase, nil))
+	p.SetOutput(out)
+	if err != nil {
+		if event.IsBefore() {
+			logger.Error("Hook failed, aborting node", "phase", p.Name, "event", event, "output", out)
+			return p.Fail(err)
+		}
+		_ = p.Fail(err)
+		logger.Warn("Hook failed, continuing", "phase", p.Name, "event", event, "error", err, "output", out)
+		return nil
+	}
+	p.Succeed()
+	return nil
+}
+
+// runFailureHooks runs the on-failure hooks of a node whose restart failed
+// with err. They are not recorded as a phase, so that the failed phase stays
+// the last one on the report.
+func (r *rollout) runFailureHooks(ctx context.Context, nr *report.NodeReport, logger *log.Logger, err error) {
+	if !r.hooks.Has(hooks.OnFailure) {
+		return
+	}
+	var phase report.Phase
+	if p := nr.FailedPhase(); p != nil {
+		phase = p.Name
+	}
+	if out, err := r.hooks.Run(ctx, r.hookPayload(nr, hooks.OnFailure, phase, err)); err != nil {
+		logger.Warn("On-failure hook failed", "event", hooks.OnFailure, "error", err, "output", out)
+	}
+}
+
+func (r *rollout) hookPayload(nr *report.NodeReport, event hooks.Event, phase report.Phase, err error) hooks.Payload {
+	p := hooks.Payload{
+		Event:        event,
+		RunID:        r.runID,
+		Node:         nr.Name,
+		Phase:        string(phase),
+		Time:         time.Now().UTC(),
+		Operator:     r.identity,
+		DryRun:       r.cfg.DryRun,
+		BootIDBefore: nr.BootIDBefore,
+		BootIDAfter:  nr.BootIDAfter,
+		PodsEvicted:  nr.PodsEvicted,
+	}
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return p
+}
+
+// silenceAlerts silences the node's alerts in Alertmanager for the reboot
+// and returns the silence ID, or "" without --alertmanager-url.
+func (r *rollout) silenceAlerts(ctx context.Context, nr *report.NodeReport, logger *log.Logger) (string, error) {
+	if r.silencer == nil {
+		return "", nil
+	}
+	if r.cfg.DryRun {
+		nr.SkipPhase(report.PhaseSilence, "dry-run")
+		logger.Info("Alert silence skipped (dry-run)", "phase", report.PhaseSilence)
+		return "", nil
+	}
+	p := nr.StartPhase(report.PhaseSilence)
+	comment := fmt.Sprintf("kubectl-reboot run %s: rebooting node %s", r.runID, nr.Name)
+	id, err := r.silencer.Silence(ctx, nr.Name, comment)
+	if err != nil {
+		return "", p.Fail(fmt.Errorf("silence alerts: %w", err))
+	}
+	nr.SetSilenceID(id)
+	p.Succeed()
+	logger.Info("Alerts silenced", "phase", p.Name, "silence_id", id)
+	return id, nil
+}
+
+// expireSilence ends a silence created by silenceAlerts. A failure is only
+// logged: the silence still ends after --silence-duration.
+func (r *rollout) expireSilence(ctx context.Context, logger *log.Logger, id string) {
+	if err := r.silencer.Expire(ctx, id); err != nil {
+		logger.Warn("Failed to expire alert silence", "silence_id", id, "error", err)
+		return
+	}
+	logger.Info("Alert silence expired", "silence_id", id)
+}
+
+// runRemoteCmd runs a --pre-reboot-cmd or --post-reboot-cmd on the node and
+// records its output on the phase. With --remote-cmd-failure=warn a failed
+// command is recorded but does not fail the node.
+func (r *rollout) runRemoteCmd(nr *report.NodeReport, logger *log.Logger, phase report.Phase, host, command string) error {
+	if command == "" {
+		return nil
+	}
+	p := nr.StartPhase(phase)
+	logger.Info("Running remote command", "phase", p.Name, "command", command)
+	out, err := r.ssh.Output(logger.With("phase", p.Name), host, command, time.Duration(r.cfg.RemoteCmdTimeoutSeconds)*time.Second)
+	p.SetOutput(out)
+	if err != nil {
+		if r.cfg.RemoteCmdFailure == config.RemoteCmdWarn {
+			_ = p.Fail(err)
+			logger.Warn("Remote command failed, continuing", "phase", p.Name, "duration", p.Duration(), "error", err, "output", out)
+			return nil
+		}
+		logger.Error("Remote command failed", "phase", p.Name, "duration", p.Duration(), "output", out)
+		return p.Fail(fmt.Errorf("%s command: %w", phase, err))
+	}
+	p.Succeed()
+	logger.Info("Remote command completed", "phase", p.Name, "duration", p.Duration())
+	logger.Debug("Remote command output", "phase", p.Name, "output", out)
+	return nil
+}
+
+// runHealthGates evaluates the --health-gate checks in order, polling each
+// until it passes or its own timeout expires.
+func (r *rollout) runHealthGates(ctx context.Context, nr *report.NodeReport, logger *log.Logger) error {
+	if len(r.cfg.HealthGates) == 0 {
+		return nil
+	}
+	p := nr.StartPhase(report.PhaseHealthGates)
+	interval := time.Duration(r.cfg.PollIntervalSeconds) * time.Second
+	for _, gate := range r.cfg.HealthGates {
+		logger.Info("Waiting for health gate", "phase", p.Name, "gate", gate.String(), "timeout_seconds", int(gate.Timeout.Seconds()))
+		deadline := time.Now().Add(gate.Timeout)
+		for {
+			ok, detail, err := r.checkHealthGate(ctx, nr.Name, gate)
+			if err != nil {
+				return p.Fail(fmt.Errorf("health gate %s: %w", gate, err))
+			}
+			if ok {
+				logger.Info("Health gate passed", "phase", p.Name, "gate", gate.String())
+				break
+			}
+			if !time.Now().Add(interval).Before(deadline) {
+				return p.Fail(fmt.Errorf("health gate %s not passed within %s: %s", gate, gate.Timeout, detail))
+			}
+			logger.Debug("Health gate not passed yet", "phase", p.Name, "gate", gate.String(), "detail", detail)
+			select {
+			case <-ctx.Done():
+				return p.Fail(ctx.Err())
+			case <-time.After(interval):
+			}
+		}
+	}
+	p.Succeed()
+	logger.Info("Health gates passed", "phase", p.Name, "duration", p.Duration(), "gates", len(r.cfg.HealthGates))
+	return nil
+}
+
+func (r *rollout) checkHealthGate(ctx context.Context, node string, gate config.HealthGate) (bool, string, error) {
+	switch gate.Kind {
+	case config.GateDaemonSets:
+		return r.kc.DaemonSetPodsReady(ctx, node)
+	case config.GateCondition:
+		typ, status, _ := strings.Cut(gate.Arg, "=")
+		return r.kc.NodeConditionIs(ctx, node, typ, corev1.ConditionStatus(status))
+	case config.GatePods:
+		return r.kc.PodsReady(ctx, node, gate.Arg)
+	}
+	return false, "", fmt.Errorf("unknown health gate kind %q", gate.Kind)
+}
+
+// checkCapacity verifies that the other schedulable nodes can absorb the
+// pods about to be evicted from the node. With --capacity-check=pause it
+// re-checks every poll interval until --capacity-timeout before failing.
+func (r *rollout) checkCapacity(ctx context.Context, nr *report.NodeReport, logger *log.Logger, pods []corev1.Pod) error {
+	mode := r.cfg.CapacityCheck
+	if mode == "" || mode == config.CapacityCheckOff {
+		return nil
+	}
+	p := nr.StartPhase(report.PhaseCapacity)
+	// Nodes restarted alongside this one cannot take its pods.
+	exclude := r.batchOf(nr.Name)
+	interval := time.Duration(r.cfg.PollIntervalSeconds) * time.Second
+	deadline := time.Now().Add(time.Duration(r.cfg.CapacityTimeoutSeconds) * time.Second)
+	for {
+		check, err := r.kc.CheckCapacity(ctx, nr.Name, pods, exclude)
+		if err != nil {
+			return p.Fail(fmt.Errorf("capacity check: %w", err))
+		}
+		if check.OK() {
+			p.Succeed()
+			logger.Info("Capacity check passed", "phase", p.Name, "duration", p.Duration(), "pods", check.Pods, "candidate_nodes", check.Candidates)
+			return nil
+		}
+		if mode != config.CapacityCheckPause || !time.Now().Add(interval).Before(deadline) {
+			return p.Fail(fmt.Errorf("insufficient capacity: %s", check))
+		}
+		logger.Warn("Insufficient capacity, waiting", "phase", p.Name, "unplaceable", strings.Join(check.Unplaceable, ","), "candidate_nodes", check.Candidates)
+		select {
+		case <-ctx.Done():
+			return p.Fail(ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// batchOf returns the other nodes in node's batch.
+func (r *rollout) batchOf(node string) []string {
+	for _, batch := range r.cfg.Batches() {
+		for _, n := range batch {
+			if n != node {
+				continue
+			}
+			var others []string
+			for _, o := range batch {
+				if o != node {
+					others = append(others, o)
+				}
+			}
+			return others
+		}
+	}
+	return nil
+}
+
+// applyFailurePolicy leaves a node whose restart failed in the state chosen
+// by --on-failure for the failed phase and records the choice on the report.
+func (r *rollout) applyFailurePolicy(ctx context.Context, nr *report.NodeReport, logger *log.Logger) {
+	p := nr.FailedPhase()
+	if p == nil || p.Name == report.PhaseSettle {
+		// After uncordon there is no node state left to decide on.
+		return
+	}
+	policy := r.cfg.FailurePolicy(string(p.Name))
+	nr.SetOnFailure(policy)
+	if r.cfg.DryRun {
+		logger.Info("Failure policy skipped (dry-run)", "phase", p.Name, "on_failure", policy)
+		return
+	}
+
+	// A node somebody else had cordoned stays cordoned whatever the policy.
+	uncordon := !r.keepCordoned(nr)
+	var err error
+	switch policy {
+	case config.OnFailureUncordon:
+		if uncordon {
+			err = r.kc.Uncordon(ctx, nr.Name)
+		}
+	case config.OnFailureTaint:
+		// The taint keeps pods off the node and marks it for inspection
+		// without it looking like a routine maintenance cordon.
+		taint := corev1.Taint{Key: kube.TaintFailed, Value: string(p.Name), Effect: corev1.TaintEffectNoSchedule}
+		if err = r.kc.TaintNode(ctx, nr.Name, taint); err == nil && uncordon {
+			err = r.kc.Uncordon(ctx, nr.Name)
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to apply failure policy", "phase", p.Name, "on_failure", policy, "error", err)
+		return
+	}
+	logger.Warn("Failure policy applied", "phase", p.Name, "on_failure", policy, "previously_cordoned", nr.PreviouslyCordoned)
+}
+
+// keepCordoned reports whether the node must be left cordoned because it
+// was cordoned by someone else before the run and --force-uncordon is unset.
+func (r *rollout) keepCordoned(nr *report.NodeReport) bool {
+	return nr.PreviouslyCordoned && !r.cfg.ForceUncordon
+}
+
+// recordEvent emits an Event on the node. Events are best effort: a failure
+// is logged and never fails the node.
+func (r *rollout) recordEvent(ctx context.Context, nd *corev1.Node, eventType, reason, message string) {
+	if r.cfg.DryRun {
+		return
+	}
+	if err := r.kc.RecordNodeEvent(ctx, nd, eventType, reason, message); err != nil {
+		log.Warn("Failed to record node event", "node", nd.Name, "reason", reason, "error", err)
+	}
+}
+
+// annotateReboot records the reboot on the node so it is visible from the
+// cluster which nodes were restarted, when, and by whom.
+func (r *rollout) annotateReboot(ctx context.Context, nodeName, bootBefore, bootAfter string) {
+	if r.cfg.DryRun {
+		return
+	}
+	annotations := map[string]string{
+		kube.AnnotationLastRebootTime: time.Now().UTC().Format(time.RFC3339),
+		kube.AnnotationLastRebootBy:   r.identity,
+		kube.AnnotationToolVersion:    version,
+	}
+	if bootBefore != "" {
+		annotations[kube.AnnotationPreviousBootID] = bootBefore
+	}
+	if bootAfter != "" {
+		annotations[kube.AnnotationBootID] = bootAfter
+	}
+	if err := r.kc.AnnotateNode(ctx, nodeName, annotations); err != nil {
+		log.Warn("Failed to annotate node", "node", nodeName, "error", err)
+	}
+}
+
+// operatorIdentity names whoever runs the tool as user@host.
+func operatorIdentity() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if h, err := os.Hostname(); err == nil {
+		name += "@" + h
+	}
+	return name
+}
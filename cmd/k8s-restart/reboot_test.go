@@ -0,0 +1,941 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/alertmanager"
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/hooks"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	sshpkg "github.com/ayetkin/kubectl-reboot/internal/ssh"
+	"github.com/ayetkin/kubectl-reboot/internal/window"
+	"github.com/charmbracelet/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// newTestRollout returns a dry-run rollout against a fake cluster.
+func newTestRollout(cfg *config.Config, nodes ...string) *rollout {
+	var objs []runtime.Object
+	for _, n := range nodes {
+		objs = append(objs, testNode(n, false))
+	}
+	kclient := newFakeClient(objs...)
+	return &rollout{
+		ctx:    context.Background(),
+		cfg:    cfg,
+		kc:     kclient,
+		ssh:    &sshpkg.Runner{DryRun: cfg.DryRun},
+		report: report.New(cfg.DryRun),
+	}
+}
+
+func TestProcessNodeDryRunReport(t *testing.T) {
+	cfg := &config.Config{
+		DryRun:          true,
+		SSHHostTemplate: "%s",
+		RebootCmd:       config.DefaultRebootCmd,
+		RebootMethod:    config.RebootMethodSSH,
+		NodeOverrides:   map[string]config.NodeOverride{"node2": {RebootMethod: config.RebootMethodNone}},
+	}
+	r := newTestRollout(cfg, "node1", "node2")
+
+	for _, n := range []string{"node1", "node2"} {
+		if err := r.processNode(n); err != nil {
+			t.Fatalf("processNode(%s) error = %v", n, err)
+		}
+	}
+	r.report.Finish()
+
+	if r.report.Totals.Succeeded != 2 {
+		t.Fatalf("Expected 2 succeeded nodes, got %+v", r.report.Totals)
+	}
+	statuses := map[report.Phase]report.Status{}
+	for _, p := range r.report.Nodes[1].Phases {
+		statuses[p.Name] = p.Status
+	}
+	want := map[report.Phase]report.Status{
+		report.PhaseCordon:     report.StatusSucceeded,
+		report.PhaseDrain:      report.StatusSucceeded,
+		report.PhaseReboot:     report.StatusSkipped,
+		report.PhaseWaitBootID: report.StatusSkipped,
+		report.PhaseWaitReady:  report.StatusSkipped,
+		report.PhaseUncordon:   report.StatusSucceeded,
+	}
+	for phase, status := range want {
+		if statuses[phase] != status {
+			t.Errorf("node2 phase %s = %q, want %q", phase, statuses[phase], status)
+		}
+	}
+
+	// Dry-run must not have touched the cluster.
+	nd, err := r.kc.GetNode(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Spec.Unschedulable {
+		t.Error("dry-run cordoned node1")
+	}
+}
+
+func TestProcessNodeMissingNode(t *testing.T) {
+	r := newTestRollout(&config.Config{DryRun: true})
+	if err := r.processNode("ghost"); err == nil {
+		t.Fatal("Expected error for a node that does not exist")
+	}
+	r.report.Finish()
+	if r.report.Totals.Failed != 1 || r.report.Nodes[0].Error == "" {
+		t.Errorf("failure not recorded in report: %+v", r.report.Nodes[0])
+	}
+}
+
+func TestRunBatchesMaxFailures(t *testing.T) {
+	tests := []struct {
+		name             string
+		batchSize        int
+		maxFailures      int
+		wantFailures     []string
+		wantNotAttempted []string
+	}{
+		{name: "abort after first failure", batchSize: 1, maxFailures: 1, wantFailures: []string{"ghost1"}, wantNotAttempted: []string{"node2", "ghost3", "node4"}},
+		{name: "abort after second failure", batchSize: 1, maxFailures: 2, wantFailures: []string{"ghost1", "ghost3"}, wantNotAttempted: []string{"node4"}},
+		{name: "in-flight batch completes", batchSize: 3, maxFailures: 1, wantFailures: []string{"ghost1", "ghost3"}, wantNotAttempted: []string{"node4"}},
+		{name: "no limit", batchSize: 1, maxFailures: 0, wantFailures: []string{"ghost1", "ghost3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DryRun:       true,
+				Nodes:        []string{"ghost1", "node2", "ghost3", "node4"},
+				BatchSize:    tt.batchSize,
+				MaxFailures:  tt.maxFailures,
+				RebootMethod: config.RebootMethodNone,
+			}
+			r := newTestRollout(cfg, "node2", "node4")
+
+			failures, notAttempted := r.runBatches()
+			if strings.Join(failures, ",") != strings.Join(tt.wantFailures, ",") {
+				t.Errorf("failures = %v, want %v", failures, tt.wantFailures)
+			}
+			if strings.Join(notAttempted, ",") != strings.Join(tt.wantNotAttempted, ",") {
+				t.Errorf("notAttempted = %v, want %v", notAttempted, tt.wantNotAttempted)
+			}
+			r.report.Finish()
+			if r.report.Aborted != (len(tt.wantNotAttempted) > 0) || r.report.Totals.NotAttempted != len(tt.wantNotAttempted) {
+				t.Errorf("unexpected report aborted=%v totals=%+v", r.report.Aborted, r.report.Totals)
+			}
+		})
+	}
+}
+
+func TestRunBatchesInteractive(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		wantFailures     []string
+		wantNotAttempted []string
+		wantTotals       report.Totals
+		wantRetries      int
+	}{
+		{name: "continue", input: "c\ncontinue\n", wantFailures: []string{"ghost1"}, wantTotals: report.Totals{Nodes: 3, Succeeded: 2, Failed: 1}},
+		{name: "skip next", input: "s\n", wantFailures: []string{"ghost1"}, wantTotals: report.Totals{Nodes: 3, Succeeded: 1, Failed: 1, Skipped: 1}},
+		{name: "abort", input: "x\na\n", wantFailures: []string{"ghost1"}, wantNotAttempted: []string{"node2", "node3"}, wantTotals: report.Totals{Nodes: 3, Failed: 1, NotAttempted: 2}},
+		{name: "closed input aborts", input: "", wantFailures: []string{"ghost1"}, wantNotAttempted: []string{"node2", "node3"}, wantTotals: report.Totals{Nodes: 3, Failed: 1, NotAttempted: 2}},
+		{name: "retry", input: "r\nc\nc\n", wantFailures: []string{"ghost1"}, wantTotals: report.Totals{Nodes: 3, Succeeded: 2, Failed: 1}, wantRetries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DryRun:       true,
+				Nodes:        []string{"ghost1", "node2", "node3"},
+				BatchSize:    1,
+				RebootMethod: config.RebootMethodNone,
+			}
+			r := newTestRollout(cfg, "node2", "node3")
+			var out strings.Builder
+			r.step = newStepper(strings.NewReader(tt.input), &out)
+
+			failures, notAttempted := r.runBatches()
+			if strings.Join(failures, ",") != strings.Join(tt.wantFailures, ",") {
+				t.Errorf("failures = %v, want %v", failures, tt.wantFailures)
+			}
+			if strings.Join(notAttempted, ",") != strings.Join(tt.wantNotAttempted, ",") {
+				t.Errorf("notAttempted = %v, want %v", notAttempted, tt.wantNotAttempted)
+			}
+			r.report.Finish()
+			if r.report.Totals != tt.wantTotals {
+				t.Errorf("Totals = %+v, want %+v", r.report.Totals, tt.wantTotals)
+			}
+			if got := r.report.Lookup("ghost1").Retries; got != tt.wantRetries {
+				t.Errorf("ghost1 retries = %d, want %d", got, tt.wantRetries)
+			}
+			if !strings.Contains(out.String(), "Batch 1/3 finished") || !strings.Contains(out.String(), "ghost1: ") {
+				t.Errorf("batch summary missing:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestPhaseTimings(t *testing.T) {
+	rep := report.New(false)
+	nr := rep.Node("node1")
+	nr.StartPhase(report.PhaseCordon).Succeed()
+	nr.SkipPhase(report.PhaseReboot, "dry-run")
+	_ = nr.StartPhase(report.PhaseDrain).Fail(errors.New("boom"))
+	if got, want := phaseTimings(nr), "cordon 0s, drain failed after 0s"; got != want {
+		t.Errorf("phaseTimings() = %q, want %q", got, want)
+	}
+}
+
+func TestRunBatchesWindowClosing(t *testing.T) {
+	w, err := window.Parse("00:00-24:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Nodes:               []string{"node1", "node2"},
+		BatchSize:           1,
+		RebootMethod:        config.RebootMethodNone,
+		Window:              w,
+		NodeEstimateSeconds: 48 * 3600,
+	}
+	r := newTestRollout(cfg, "node1", "node2")
+
+	failures, notAttempted := r.runBatches()
+	if len(failures) != 0 || strings.Join(notAttempted, ",") != "node1,node2" {
+		t.Errorf("runBatches() = %v, %v; want no failures and both nodes not attempted", failures, notAttempted)
+	}
+	r.report.Finish()
+	if !r.report.WindowClosed || r.report.Aborted || r.report.Totals.NotAttempted != 2 {
+		t.Errorf("unexpected report windowClosed=%v aborted=%v totals=%+v", r.report.WindowClosed, r.report.Aborted, r.report.Totals)
+	}
+}
+
+func TestRunBatchesLockLost(t *testing.T) {
+	cfg := &config.Config{Nodes: []string{"node1", "node2"}, BatchSize: 1, RebootMethod: config.RebootMethodNone}
+	r := newTestRollout(cfg, "node1", "node2")
+	ctx := context.Background()
+	lock, err := r.kc.AcquireLock(ctx, kube.DefaultLockNamespace, "alice@laptop", "run-a", time.Minute, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.lock = lock
+	lock.KeepAlive(time.Millisecond)
+	defer func() { _ = lock.Release(ctx) }()
+	if _, err := r.kc.AcquireLock(ctx, kube.DefaultLockNamespace, "bob@ci", "run-b", time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock loss was not reported")
+	}
+
+	failures, notAttempted := r.runBatches()
+	if len(failures) != 0 || strings.Join(notAttempted, ",") != "node1,node2" {
+		t.Errorf("runBatches() = %v, %v; want no failures and both nodes not attempted", failures, notAttempted)
+	}
+	r.report.Finish()
+	if !r.report.Aborted {
+		t.Error("expected the report to be marked aborted")
+	}
+}
+
+func TestWindowAllows(t *testing.T) {
+	w, err := window.Parse("Mon-Fri 01:00-05:00 UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2025-10-03 is a Friday.
+	at := func(hhmm string) time.Time {
+		tm, _ := time.Parse("2006-01-02 15:04", "2025-10-03 "+hhmm)
+		return tm
+	}
+	tests := []struct {
+		name   string
+		dryRun bool
+		at     string
+		want   bool
+	}{
+		{name: "enough time left", at: "03:00", want: true},
+		{name: "too little time left", at: "04:45"},
+		{name: "window closed", at: "06:00"},
+		{name: "dry-run ignores the window", dryRun: true, at: "06:00", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRollout(&config.Config{Window: w, DryRun: tt.dryRun})
+			if got := r.windowAllows(at(tt.at), 30*time.Minute); got != tt.want {
+				t.Errorf("windowAllows(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipRecentlyRebooted(t *testing.T) {
+	now := time.Date(2025, 10, 3, 3, 0, 0, 0, time.UTC)
+	recent := testNode("recent", false)
+	recent.Annotations = map[string]string{kube.AnnotationLastRebootTime: now.Add(-2 * time.Hour).Format(time.RFC3339)}
+	old := testNode("old", false)
+	old.Annotations = map[string]string{kube.AnnotationLastRebootTime: now.Add(-72 * time.Hour).Format(time.RFC3339)}
+	never := testNode("never", false)
+
+	cfg := &config.Config{Nodes: []string{"recent", "old", "never"}, SkipRebootedWithin: 24 * time.Hour}
+	if err := skipRecentlyRebooted(cfg, newFakeClient(recent, old, never), now); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(cfg.Nodes, ","); got != "old,never" {
+		t.Errorf("nodes = %s, want old,never", got)
+	}
+}
+
+func TestApplyFailurePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		phase         report.Phase
+		onFailure     map[string]string
+		wantPolicy    string
+		wantCordoned  bool
+		wantFailTaint bool
+	}{
+		{name: "drain failure uncordons by default", phase: report.PhaseDrain, wantPolicy: config.OnFailureUncordon},
+		{name: "wait-ready failure stays cordoned by default", phase: report.PhaseWaitReady, wantPolicy: config.OnFailureLeaveCordoned, wantCordoned: true},
+		{name: "drain failure taints", phase: report.PhaseDrain, onFailure: map[string]string{"drain": "taint"}, wantPolicy: config.OnFailureTaint, wantFailTaint: true},
+		{name: "drain failure left cordoned", phase: report.PhaseDrain, onFailure: map[string]string{"drain": "leave-cordoned"}, wantPolicy: config.OnFailureLeaveCordoned, wantCordoned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRollout(&config.Config{OnFailure: tt.onFailure})
+			r.kc = newFakeClient(testNode("node1", true))
+			nr := r.report.Node("node1")
+			nr.StartPhase(report.PhaseCordon).Succeed()
+			_ = nr.StartPhase(tt.phase).Fail(errors.New("boom"))
+
+			r.applyFailurePolicy(context.Background(), nr, log.Default())
+
+			if nr.OnFailure != tt.wantPolicy {
+				t.Errorf("OnFailure = %q, want %q", nr.OnFailure, tt.wantPolicy)
+			}
+			nd, err := r.kc.GetNode(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Spec.Unschedulable != tt.wantCordoned {
+				t.Errorf("unschedulable = %v, want %v", nd.Spec.Unschedulable, tt.wantCordoned)
+			}
+			if hasTaint(nd, kube.TaintFailed) != tt.wantFailTaint {
+				t.Errorf("failure taint = %v, want %v", nd.Spec.Taints, tt.wantFailTaint)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{err: errRunAborted, want: exitAborted},
+		{err: errNodesFailed, want: exitError},
+		{err: errInterrupted, want: exitInterruptedRun},
+		{err: errors.New("no nodes provided"), want: exitError},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProcessNodePreservesCordon(t *testing.T) {
+	tests := []struct {
+		name          string
+		cordonedBy    string
+		forceUncordon bool
+		wantCordoned  bool
+		wantPrevious  bool
+	}{
+		{name: "cordoned by someone else", wantCordoned: true, wantPrevious: true},
+		{name: "cordoned by someone else, forced", forceUncordon: true, wantPrevious: true},
+		{name: "cordoned by an earlier run", cordonedBy: "bob@ci"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{RebootMethod: config.RebootMethodNone, TimeoutReadySeconds: 1, ForceUncordon: tt.forceUncordon}
+			r := newTestRollout(cfg)
+			node := testNode("node1", true)
+			if tt.cordonedBy != "" {
+				node.Annotations = map[string]string{kube.AnnotationCordonedBy: tt.cordonedBy}
+			}
+			r.kc = newFakeClient(node)
+
+			if err := r.processNode("node1"); err != nil {
+				t.Fatalf("processNode() error = %v", err)
+			}
+			nd, err := r.kc.GetNode(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Spec.Unschedulable != tt.wantCordoned {
+				t.Errorf("unschedulable = %v, want %v", nd.Spec.Unschedulable, tt.wantCordoned)
+			}
+			if nr := r.report.Nodes[0]; nr.PreviouslyCordoned != tt.wantPrevious {
+				t.Errorf("PreviouslyCordoned = %v, want %v", nr.PreviouslyCordoned, tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestProcessNodeCapacityCheck(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}}
+	tests := []struct {
+		name      string
+		mode      string
+		nodes     []runtime.Object
+		wantError bool
+	}{
+		{name: "fails without room elsewhere", mode: config.CapacityCheckFail, nodes: []runtime.Object{testNode("node1", false)}, wantError: true},
+		{name: "passes with another node", mode: config.CapacityCheckFail, nodes: []runtime.Object{testNode("node1", false), testNode("node2", false)}},
+		{name: "off", mode: config.CapacityCheckOff, nodes: []runtime.Object{testNode("node1", false)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DryRun: true, CapacityCheck: tt.mode, Nodes: []string{"node1"}, BatchSize: 1}
+			r := newTestRollout(cfg)
+			r.kc = newFakeClient(append(tt.nodes, pod)...)
+
+			err := r.processNode("node1")
+			if (err != nil) != tt.wantError {
+				t.Fatalf("processNode() error = %v, wantError %v", err, tt.wantError)
+			}
+			nr := r.report.Nodes[0]
+			if tt.wantError {
+				if p := nr.FailedPhase(); p == nil || p.Name != report.PhaseCapacity {
+					t.Errorf("failed phase = %+v, want %s", p, report.PhaseCapacity)
+				}
+				if nr.Phase(report.PhaseCordon) != nil {
+					t.Error("node must not be cordoned when capacity is insufficient")
+				}
+			}
+		})
+	}
+}
+
+func TestProcessNodeWaitWorkloads(t *testing.T) {
+	yes := true
+	tests := []struct {
+		name      string
+		available int32
+		wantErr   bool
+	}{
+		{name: "workloads available", available: 2},
+		{name: "workloads short of replicas", available: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replicas := int32(2)
+			deploy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status:     appsv1.DeploymentStatus{AvailableReplicas: tt.available},
+			}
+			rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+				Name: "web-7d9c", Namespace: "default",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &yes}},
+			}}
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web-7d9c-a", Namespace: "default",
+					OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-7d9c", Controller: &yes}},
+				},
+				Spec: corev1.PodSpec{NodeName: "node1"},
+			}
+			cfg := &config.Config{RebootMethod: config.RebootMethodNone, TimeoutReadySeconds: 1, WaitWorkloads: true}
+			r := newTestRollout(cfg)
+			r.kc = newEvictingFakeClient(testNode("node1", false), deploy, rs, pod)
+
+			err := r.processNode("node1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			nr := r.report.Nodes[0]
+			if tt.wantErr {
+				if p := nr.FailedPhase(); p == nil || p.Name != report.PhaseWaitWorkloads {
+					t.Errorf("failed phase = %+v, want %s", p, report.PhaseWaitWorkloads)
+				}
+				if nr.OnFailure != config.OnFailureUncordon {
+					t.Errorf("OnFailure = %q, want %q", nr.OnFailure, config.OnFailureUncordon)
+				}
+				return
+			}
+			for _, phase := range []report.Phase{report.PhaseWaitWorkloads, report.PhaseSettle} {
+				if p := nr.Phase(phase); p == nil || p.Status != report.StatusSucceeded {
+					t.Errorf("phase %s = %+v, want succeeded", phase, p)
+				}
+			}
+		})
+	}
+}
+
+func TestProcessNodeHealthGates(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []corev1.NodeCondition
+		wantErr    bool
+	}{
+		{name: "gate passes", conditions: []corev1.NodeCondition{{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse}}},
+		{name: "condition not reported", wantErr: true},
+		{name: "condition with wrong status", conditions: []corev1.NodeCondition{{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate, err := config.ParseHealthGate("condition:MemoryPressure=False", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config.Config{
+				RebootMethod:        config.RebootMethodNone,
+				TimeoutReadySeconds: 1,
+				PollIntervalSeconds: 1,
+				HealthGates:         []config.HealthGate{gate},
+			}
+			node := testNode("node1", false)
+			node.Status.Conditions = append(node.Status.Conditions, tt.conditions...)
+			r := newTestRollout(cfg)
+			r.kc = newFakeClient(node)
+
+			err = r.processNode("node1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			nd, _ := r.kc.GetNode(context.Background(), "node1")
+			nr := r.report.Nodes[0]
+			if !tt.wantErr {
+				if p := nr.Phase(report.PhaseHealthGates); p == nil || p.Status != report.StatusSucceeded {
+					t.Errorf("health-gates phase = %+v, want succeeded", p)
+				}
+				if nd.Spec.Unschedulable {
+					t.Error("node should be uncordoned after the gates passed")
+				}
+				return
+			}
+			if p := nr.FailedPhase(); p == nil || p.Name != report.PhaseHealthGates {
+				t.Errorf("failed phase = %+v, want %s", p, report.PhaseHealthGates)
+			}
+			if nr.Phase(report.PhaseUncordon) != nil {
+				t.Error("uncordon should not run after a failed health gate")
+			}
+			if !nd.Spec.Unschedulable {
+				t.Error("node should stay cordoned after a failed health gate")
+			}
+		})
+	}
+}
+
+// fakeRunner records the commands run on the nodes and fails those listed
+// in fail.
+type fakeRunner struct {
+	commands []string
+	fail     map[string]bool
+}
+
+func (f *fakeRunner) Run(logger *log.Logger, host, command string) error {
+	_, err := f.Output(logger, host, command, 0)
+	return err
+}
+
+func (f *fakeRunner) Output(_ *log.Logger, _, command string, _ time.Duration) (string, error) {
+	f.commands = append(f.commands, command)
+	if f.fail[command] {
+		return "smoke test failed", errors.New("exit status 1")
+	}
+	return "ok", nil
+}
+
+func TestProcessNodeRemoteCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		fail       string
+		policy     string
+		wantErr    bool
+		wantPhase  report.Phase
+		wantCmds   []string
+		wantStatus report.Status
+	}{
+		{name: "both succeed", wantCmds: []string{"pre", "post"}, wantPhase: report.PhasePostReboot, wantStatus: report.StatusSucceeded},
+		{name: "pre fails and aborts", fail: "pre", policy: config.RemoteCmdAbort, wantErr: true, wantCmds: []string{"pre"}, wantPhase: report.PhasePreReboot, wantStatus: report.StatusFailed},
+		{name: "post fails and aborts", fail: "post", policy: config.RemoteCmdAbort, wantErr: true, wantCmds: []string{"pre", "post"}, wantPhase: report.PhasePostReboot, wantStatus: report.StatusFailed},
+		{name: "post fails with warn", fail: "post", policy: config.RemoteCmdWarn, wantCmds: []string{"pre", "post"}, wantPhase: report.PhasePostReboot, wantStatus: report.StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				RebootMethod:        config.RebootMethodNone,
+				TimeoutReadySeconds: 1,
+				PreRebootCmd:        "pre",
+				PostRebootCmd:       "post",
+				RemoteCmdFailure:    tt.policy,
+			}
+			r := newTestRollout(cfg, "node1")
+			runner := &fakeRunner{fail: map[string]bool{tt.fail: true}}
+			r.ssh = runner
+
+			err := r.processNode("node1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(runner.commands, ",") != strings.Join(tt.wantCmds, ",") {
+				t.Errorf("commands = %v, want %v", runner.commands, tt.wantCmds)
+			}
+			nr := r.report.Nodes[0]
+			p := nr.Phase(tt.wantPhase)
+			if p == nil || p.Status != tt.wantStatus || p.Output == "" {
+				t.Fatalf("phase %s = %+v, want %s with output", tt.wantPhase, p, tt.wantStatus)
+			}
+			if tt.wantErr {
+				return
+			}
+			if nr.Status != report.StatusSucceeded || nr.Phase(report.PhaseUncordon) == nil {
+				t.Errorf("node should complete despite a warned failure, got %s", nr.Status)
+			}
+		})
+	}
+}
+
+func TestProcessNodeHooks(t *testing.T) {
+	dir := t.TempDir()
+	script := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	failureLog := filepath.Join(dir, "on-failure.log")
+	ok := script("ok.sh", "exit 0\n")
+	veto := script("veto.sh", "echo maintenance not allowed\nexit 1\n")
+	onFailure := script("on-failure.sh", `echo "$KUBECTL_REBOOT_PHASE" >> `+failureLog+"\n")
+
+	tests := []struct {
+		name          string
+		hooks         []hooks.Hook
+		wantErr       bool
+		wantCordoned  bool
+		wantFailPhase string
+	}{
+		{name: "all hooks succeed", hooks: []hooks.Hook{{Event: hooks.BeforeCordon, Path: ok}, {Event: hooks.AfterDrain, Path: ok}, {Event: hooks.AfterReboot, Path: ok}, {Event: hooks.BeforeUncordon, Path: ok}}},
+		{name: "failing after hook only warns", hooks: []hooks.Hook{{Event: hooks.AfterDrain, Path: veto}}},
+		{name: "before-uncordon vetoes uncordon", hooks: []hooks.Hook{{Event: hooks.BeforeUncordon, Path: veto}, {Event: hooks.OnFailure, Path: onFailure}}, wantErr: true, wantCordoned: true, wantFailPhase: "hook-before-uncordon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(failureLog)
+			cfg := &config.Config{RebootMethod: config.RebootMethodNone, TimeoutReadySeconds: 1}
+			r := newTestRollout(cfg, "node1")
+			r.hooks = &hooks.Runner{Hooks: tt.hooks, Timeout: 5 * time.Second}
+
+			err := r.processNode("node1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			nd, _ := r.kc.GetNode(context.Background(), "node1")
+			if nd.Spec.Unschedulable != tt.wantCordoned {
+				t.Errorf("node cordoned = %v, want %v", nd.Spec.Unschedulable, tt.wantCordoned)
+			}
+			for _, h := range tt.hooks {
+				if h.Event == hooks.OnFailure {
+					continue
+				}
+				if p := r.report.Nodes[0].Phase(report.HookPhase(string(h.Event))); p == nil {
+					t.Errorf("no phase recorded for %s hook", h.Event)
+				}
+			}
+			if tt.wantFailPhase == "" {
+				return
+			}
+			if p := r.report.Nodes[0].FailedPhase(); p == nil || string(p.Name) != tt.wantFailPhase || !strings.Contains(p.Output, "maintenance not allowed") {
+				t.Errorf("failed phase = %+v, want %s with hook output", p, tt.wantFailPhase)
+			}
+			data, err := os.ReadFile(failureLog)
+			if err != nil || strings.TrimSpace(string(data)) != tt.wantFailPhase {
+				t.Errorf("on-failure hook got phase %q (%v), want %s", data, err, tt.wantFailPhase)
+			}
+		})
+	}
+}
+
+func TestProcessNodeSilencesAlerts(t *testing.T) {
+	tests := []struct {
+		name        string
+		ready       bool
+		wantErr     bool
+		wantExpired bool
+	}{
+		{name: "expired once ready", ready: true, wantExpired: true},
+		{name: "expired when the node fails", ready: false, wantErr: true, wantExpired: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				requests = append(requests, req.Method+" "+req.URL.Path)
+				if req.Method == http.MethodPost {
+					_, _ = w.Write([]byte(`{"silenceID":"a1b2"}`))
+				}
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{RebootMethod: config.RebootMethodNone, TimeoutReadySeconds: 1}
+			node := testNode("node1", false)
+			if !tt.ready {
+				node.Status.Conditions[0].Status = corev1.ConditionFalse
+			}
+			r := newTestRollout(cfg)
+			r.kc = newFakeClient(node)
+			r.silencer = &alertmanager.Client{URL: srv.URL, Matchers: []alertmanager.Matcher{{Name: "node", Value: alertmanager.NodePlaceholder, IsEqual: true}}, Duration: time.Hour}
+
+			err := r.processNode("node1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := []string{"POST /api/v2/silences", "DELETE /api/v2/silence/a1b2"}
+			if strings.Join(requests, ",") != strings.Join(want, ",") {
+				t.Errorf("Alertmanager requests = %v, want %v", requests, want)
+			}
+			nr := r.report.Nodes[0]
+			if nr.SilenceID != "a1b2" {
+				t.Errorf("SilenceID = %q, want a1b2", nr.SilenceID)
+			}
+			if p := nr.Phase(report.PhaseSilence); p == nil || p.Status != report.StatusSucceeded {
+				t.Errorf("silence phase = %+v, want succeeded", p)
+			}
+		})
+	}
+}
+
+func TestProcessNodeRecordsHistory(t *testing.T) {
+	cfg := &config.Config{
+		RebootMethod:         config.RebootMethodNone,
+		TimeoutReadySeconds:  1,
+		TimeoutBootIDSeconds: 1,
+	}
+	r := newTestRollout(cfg, "node1")
+	r.identity = "alice@laptop"
+
+	if err := r.processNode("node1"); err != nil {
+		t.Fatalf("processNode() error = %v", err)
+	}
+
+	ctx := context.Background()
+	nd, err := r.kc.GetNode(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nd.Spec.Unschedulable {
+		t.Error("node1 should be uncordoned at the end")
+	}
+	if _, ok := nd.Annotations[kube.AnnotationCordonedBy]; ok {
+		t.Error("cordoned-by annotation should be removed on uncordon")
+	}
+	if nd.Annotations[kube.AnnotationLastRebootBy] != "alice@laptop" || nd.Annotations[kube.AnnotationLastRebootTime] == "" || nd.Annotations[kube.AnnotationToolVersion] != version {
+		t.Errorf("unexpected annotations %v", nd.Annotations)
+	}
+
+	events, err := r.kc.CS.CoreV1().Events(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, ev := range events.Items {
+		reasons = append(reasons, ev.Reason)
+	}
+	sort.Strings(reasons)
+	want := []string{eventCordoned, eventDrained, eventRebootVerified, eventUncordoned}
+	sort.Strings(want)
+	if strings.Join(reasons, ",") != strings.Join(want, ",") {
+		t.Errorf("event reasons = %v, want %v", reasons, want)
+	}
+}
+
+func TestProcessNodeUnchangedBootIDNotRecorded(t *testing.T) {
+	cfg := &config.Config{
+		RebootMethod:               config.RebootMethodNone,
+		TimeoutReadySeconds:        1,
+		TimeoutBootIDSeconds:       0,
+		AllowUncordonWithoutReboot: true,
+	}
+	r := newTestRollout(cfg, "node1")
+	ctx := context.Background()
+	nd, err := r.kc.GetNode(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nd.Status.NodeInfo.BootID = "boot-1"
+	if _, err := r.kc.CS.CoreV1().Nodes().Update(ctx, nd, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.processNode("node1"); err != nil {
+		t.Fatalf("processNode() error = %v", err)
+	}
+	nd, err = r.kc.GetNode(ctx, "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := nd.Annotations[kube.AnnotationLastRebootTime]; ok {
+		t.Errorf("node without a new boot ID recorded as rebooted: %v", nd.Annotations)
+	}
+}
+
+func TestRunBatchStoppedNode(t *testing.T) {
+	tests := []struct {
+		name          string
+		resumeStopped bool
+		wantFailures  int
+		wantStatus    report.Status
+		wantCordoned  bool
+	}{
+		{name: "CLI cleans up as a failure", wantFailures: 1, wantStatus: report.StatusFailed},
+		{name: "controller leaves it for the next run", resumeStopped: true, wantStatus: report.StatusNotAttempted, wantCordoned: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				RebootMethod:        config.RebootMethodNone,
+				TimeoutReadySeconds: 60,
+				PollIntervalSeconds: 1,
+				OnFailure:           map[string]string{string(report.PhaseWaitReady): config.OnFailureUncordon},
+			}
+			r := newTestRollout(cfg)
+			// The node never comes back Ready.
+			nd := testNode("node1", false)
+			nd.Status.Conditions[0].Status = corev1.ConditionFalse
+			r.kc = newFakeClient(nd)
+			r.resumeStopped = tt.resumeStopped
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			r.ctx = ctx
+			time.AfterFunc(100*time.Millisecond, cancel)
+
+			if failures := r.runBatch([]string{"node1"}); len(failures) != tt.wantFailures {
+				t.Errorf("failures = %v, want %d", failures, tt.wantFailures)
+			}
+			if nr := r.report.Lookup("node1"); nr.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", nr.Status, tt.wantStatus)
+			}
+			nd, err := r.kc.GetNode(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Spec.Unschedulable != tt.wantCordoned {
+				t.Errorf("unschedulable = %v, want %v", nd.Spec.Unschedulable, tt.wantCordoned)
+			}
+		})
+	}
+}
+
+func TestProcessNodeFailureEvent(t *testing.T) {
+	cfg := &config.Config{
+		RebootMethod:         config.RebootMethodNone,
+		TimeoutReadySeconds:  0,
+		TimeoutBootIDSeconds: 0,
+	}
+	r := newTestRollout(cfg, "node1")
+
+	if err := r.processNode("node1"); err == nil {
+		t.Fatal("Expected readiness timeout")
+	}
+	events, err := r.kc.CS.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := events.Items[len(events.Items)-1]
+	if last.Reason != eventFailed || last.Type != corev1.EventTypeWarning {
+		t.Errorf("expected a Failed warning event last, got %s/%s", last.Type, last.Reason)
+	}
+}
+
+func TestAcquireLockRefusesConcurrentRun(t *testing.T) {
+	cfg := &config.Config{LockNamespace: kube.DefaultLockNamespace}
+	first := newTestRollout(cfg)
+	first.identity, first.runID = "alice@laptop", "run-a"
+	release, err := first.acquireLock()
+	if err != nil {
+		t.Fatalf("acquireLock() error = %v", err)
+	}
+
+	second := &rollout{ctx: context.Background(), cfg: cfg, kc: first.kc, identity: "bob@ci", runID: "run-b"}
+	var held *kube.LockHeldError
+	if _, err := second.acquireLock(); !errors.As(err, &held) {
+		t.Fatalf("acquireLock() error = %v, want LockHeldError", err)
+	}
+
+	release()
+	release2, err := second.acquireLock()
+	if err != nil {
+		t.Fatalf("acquireLock() after release error = %v", err)
+	}
+	release2()
+}
+
+func TestRootAndRebootShareFlags(t *testing.T) {
+	root := newRootCommand()
+	reboot, _, err := root.Find([]string{"reboot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"batch-size", "output", "metrics-addr", "webhook", "force-lock"} {
+		if root.Flags().Lookup(name) == nil {
+			t.Errorf("root command is missing --%s", name)
+		}
+		if reboot.Flags().Lookup(name) == nil {
+			t.Errorf("reboot command is missing --%s", name)
+		}
+	}
+}
+
+func TestRunFlagsOnlyOnRunningCommands(t *testing.T) {
+	root := newRootCommand()
+	for _, tt := range []struct {
+		flag string
+		cmds []string
+	}{
+		{flag: "max-failures", cmds: []string{"reboot", "controller"}},
+		{flag: "batch-size", cmds: []string{"reboot", "controller", "plan"}},
+		{flag: "canary", cmds: []string{"reboot", "controller", "plan"}},
+		{flag: "soak", cmds: []string{"reboot", "controller", "plan"}},
+	} {
+		for _, name := range []string{"reboot", "controller", "plan", "status", "recover"} {
+			sub, _, err := root.Find([]string{name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := slices.Contains(tt.cmds, name)
+			if got := sub.Flags().Lookup(tt.flag) != nil; got != want {
+				t.Errorf("%s has --%s = %v, want %v", name, tt.flag, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newRecoverCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recover [flags] [NODE_NAMES...]",
+		Short: "Uncordon nodes left cordoned or tainted by a failed run",
+		Example: `  # Uncordon the nodes of an interrupted run
+  kubectl reboot recover -f nodes.txt
+
+  # Also uncordon nodes cordoned by someone else
+  kubectl reboot recover --all --force-uncordon
+
+  # Preview which nodes would be uncordoned
+  kubectl reboot recover --all --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.Complete(args); err != nil {
+				return err
+			}
+			kclient, err := newKubeClient(cfg)
+			if err != nil {
+				return err
+			}
+			return runRecover(cfg, kclient)
+		},
+	}
+	cfg.AddTargetFlags(cmd.Flags())
+	cmd.Flags().BoolVar(&cfg.DryRun, "dry-run", false, "show what would be done without executing")
+	cmd.Flags().BoolVar(&cfg.ForceUncordon, "force-uncordon", false, "also uncordon nodes that were not cordoned by kubectl-reboot")
+	registerNodeCompletions(cmd, cfg)
+	return cmd
+}
+
+func runRecover(cfg *config.Config, kclient *kube.Client) error {
+	if err := resolveTargets(cfg, kclient, false); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	var failed int
+	for _, name := range cfg.Nodes {
+		nd, err := kclient.GetNode(ctx, name)
+		if err != nil {
+			log.Error("Failed to get node", "node", name, "error", err)
+			failed++
+			continue
+		}
+		tainted := hasTaint(nd, kube.TaintFailed)
+		if !nd.Spec.Unschedulable && !tainted {
+			log.Info("Node already schedulable", "node", name)
+			continue
+		}
+		if cfg.DryRun {
+			log.Info("Uncordon skipped (dry-run)", "node", name, "failure_taint", tainted, "cordoned_by", nd.Annotations[kube.AnnotationCordonedBy])
+			continue
+		}
+		if tainted {
+			if _, err := kclient.RemoveTaint(ctx, name, kube.TaintFailed); err != nil {
+				log.Error("Failed to remove failure taint", "node", name, "error", err)
+				failed++
+				continue
+			}
+			log.Info("Failure taint removed", "node", name)
+		}
+		if !nd.Spec.Unschedulable {
+			continue
+		}
+		if nd.Annotations[kube.AnnotationCordonedBy] == "" && !cfg.ForceUncordon {
+			log.Warn("Node was not cordoned by kubectl-reboot, left cordoned", "node", name, "flag", "force-uncordon")
+			continue
+		}
+		if err := kclient.Uncordon(ctx, name); err != nil {
+			log.Error("Failed to uncordon node", "node", name, "error", err)
+			failed++
+			continue
+		}
+		log.Info("Node uncordoned", "node", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to recover %d node(s)", failed)
+	}
+	return nil
+}
+
+func hasTaint(nd *corev1.Node, key string) bool {
+	for _, t := range nd.Spec.Taints {
+		if t.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestRunRecover(t *testing.T) {
+	tests := []struct {
+		name           string
+		cordonedBy     string
+		dryRun         bool
+		forceUncordon  bool
+		expectCordoned bool
+	}{
+		{name: "uncordons nodes cordoned by kubectl-reboot", cordonedBy: "alice@laptop", expectCordoned: false},
+		{name: "dry-run leaves nodes cordoned", cordonedBy: "alice@laptop", dryRun: true, expectCordoned: true},
+		{name: "leaves nodes cordoned by someone else", expectCordoned: true},
+		{name: "force uncordons nodes cordoned by someone else", forceUncordon: true, expectCordoned: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cordoned := testNode("node1", true)
+			if tt.cordonedBy != "" {
+				cordoned.Annotations = map[string]string{kube.AnnotationCordonedBy: tt.cordonedBy}
+			}
+			kclient := newFakeClient(cordoned, testNode("node2", false))
+			cfg := &config.Config{Nodes: []string{"node1", "node2"}, DryRun: tt.dryRun, ForceUncordon: tt.forceUncordon, BatchSize: 1}
+			if err := runRecover(cfg, kclient); err != nil {
+				t.Fatalf("runRecover() error = %v", err)
+			}
+
+			nd, err := kclient.GetNode(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if nd.Spec.Unschedulable != tt.expectCordoned {
+				t.Errorf("node1 unschedulable = %v, want %v", nd.Spec.Unschedulable, tt.expectCordoned)
+			}
+		})
+	}
+}
+
+func TestRunRecoverRemovesFailureTaint(t *testing.T) {
+	tainted := testNode("node1", false)
+	tainted.Spec.Taints = []corev1.Taint{{Key: kube.TaintFailed, Value: "drain", Effect: corev1.TaintEffectNoSchedule}}
+	kclient := newFakeClient(tainted)
+	cfg := &config.Config{Nodes: []string{"node1"}, BatchSize: 1}
+	if err := runRecover(cfg, kclient); err != nil {
+		t.Fatalf("runRecover() error = %v", err)
+	}
+	nd, err := kclient.GetNode(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nd.Spec.Taints) != 0 || nd.Spec.Unschedulable {
+		t.Errorf("node1 not recovered: unschedulable=%v taints=%v", nd.Spec.Unschedulable, nd.Spec.Taints)
+	}
+}
+
+func TestRunRecoverMissingNode(t *testing.T) {
+	cfg := &config.Config{Nodes: []string{"ghost"}, BatchSize: 1}
+	if err := runRecover(cfg, newFakeClient()); err == nil {
+		t.Error("Expected error for a node that does not exist")
+	}
+}
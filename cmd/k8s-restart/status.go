@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newStatusCommand(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status [flags] [NODE_NAMES...]",
+		Short: "Show cordon state, boot time, kernel and last reboot of nodes",
+		Long: `Show cordon state, boot time, kernel and last reboot of nodes.
+
+Without node names or --file, every node in the cluster is shown. The boot
+time is approximated by the time the node last became Ready.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.Complete(args); err != nil {
+				return err
+			}
+			kclient, err := newKubeClient(cfg)
+			if err != nil {
+				return err
+			}
+			return runStatus(cfg, kclient, cmd.OutOrStdout())
+		},
+	}
+	cfg.AddTargetFlags(cmd.Flags())
+	registerNodeCompletions(cmd, cfg)
+	return cmd
+}
+
+func runStatus(cfg *config.Config, kclient *kube.Client, out io.Writer) error {
+	if err := resolveTargets(cfg, kclient, true); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tSTATUS\tREADY SINCE\tKERNEL\tLAST REBOOT")
+	for _, name := range cfg.Nodes {
+		nd, err := kclient.GetNode(ctx, name)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t-\n", name, "Unknown")
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			name,
+			nodeStatus(nd),
+			readySince(nd),
+			valueOr(nd.Status.NodeInfo.KernelVersion, "-"),
+			valueOr(nd.Annotations[kube.AnnotationLastRebootTime], "<none>"),
+		)
+	}
+	return tw.Flush()
+}
+
+// nodeStatus renders readiness and cordon state the way kubectl get nodes does.
+func nodeStatus(nd *corev1.Node) string {
+	status := "NotReady"
+	if kube.IsNodeReady(nd) {
+		status = "Ready"
+	}
+	if nd.Spec.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+func readySince(nd *corev1.Node) string {
+	for _, c := range nd.Status.Conditions {
+		if c.Type == corev1.NodeReady && c.Status == corev1.ConditionTrue && !c.LastTransitionTime.IsZero() {
+			return c.LastTransitionTime.UTC().Format(time.RFC3339)
+		}
+	}
+	return "-"
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
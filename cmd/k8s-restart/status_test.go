@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/config"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRunStatus(t *testing.T) {
+	readyAt := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	node1 := testNode("node1", false)
+	node1.Status.Conditions[0].LastTransitionTime = metav1.NewTime(readyAt)
+	node1.Status.NodeInfo.KernelVersion = "6.8.0-45-generic"
+	node1.Annotations = map[string]string{kube.AnnotationLastRebootTime: "2025-09-01T11:55:00Z"}
+	node2 := testNode("node2", true)
+
+	var out bytes.Buffer
+	cfg := &config.Config{BatchSize: 1}
+	if err := runStatus(cfg, newFakeClient(node1, node2), &out); err != nil {
+		t.Fatalf("runStatus() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected header and 2 rows, got:\n%s", out.String())
+	}
+	row1 := strings.Fields(lines[1])
+	want1 := []string{"node1", "Ready", "2025-09-01T12:00:00Z", "6.8.0-45-generic", "2025-09-01T11:55:00Z"}
+	if strings.Join(row1, " ") != strings.Join(want1, " ") {
+		t.Errorf("node1 row = %v, want %v", row1, want1)
+	}
+	row2 := strings.Fields(lines[2])
+	if row2[0] != "node2" || row2[1] != "Ready,SchedulingDisabled" || row2[len(row2)-1] != "<none>" {
+		t.Errorf("unexpected node2 row %v", row2)
+	}
+}
+
+func TestRunStatusMissingNode(t *testing.T) {
+	var out bytes.Buffer
+	cfg := &config.Config{Nodes: []string{"ghost"}, BatchSize: 1}
+	if err := runStatus(cfg, newFakeClient(), &out); err != nil {
+		t.Fatalf("runStatus() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "ghost") || !strings.Contains(out.String(), "Unknown") {
+		t.Errorf("expected ghost to be reported as Unknown:\n%s", out.String())
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVersionCommand(t *testing.T) {
+	cmd := newRootCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"version"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("version command error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "kubectl-reboot "+version) || !strings.Contains(out.String(), gitCommit) {
+		t.Errorf("unexpected version output %q", out.String())
+	}
+}
+
+func TestRootCommandSubcommands(t *testing.T) {
+	cmd := newRootCommand()
+	cmd.InitDefaultCompletionCmd()
+	for _, name := range []string{"reboot", "plan", "status", "recover", "controller", "version", "completion"} {
+		if sub, _, err := cmd.Find([]string{name}); err != nil || sub.Name() != name {
+			t.Errorf("subcommand %q not registered", name)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package alertmanager silences a node's alerts in Alertmanager while it is
+// being rebooted.
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// NodePlaceholder is replaced by the node name in matcher values.
+const NodePlaceholder = "{node}"
+
+// DefaultMatcher matches the instance label of node-level alerts, with or
+// without an exporter port.
+const DefaultMatcher = "instance=~" + NodePlaceholder + "(:[0-9]+)?"
+
+// DefaultDuration is the default lifetime of a silence, in seconds. Silences
+// are expired as soon as the node is Ready again; the lifetime only bounds
+// how long one outlives a run that was killed.
+const DefaultDuration = 3600
+
+// Matcher is an Alertmanager label matcher. Value may contain
+// NodePlaceholder.
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// ParseMatcher parses LABEL=VALUE, LABEL!=VALUE, LABEL=~REGEX or
+// LABEL!~REGEX.
+func ParseMatcher(s string) (Matcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i <= 0 {
+		return Matcher{}, fmt.Errorf("--silence-matcher %q: want LABEL=VALUE, LABEL!=VALUE, LABEL=~REGEX or LABEL!~REGEX", s)
+	}
+	m := Matcher{Name: strings.TrimSpace(s[:i])}
+	rest := s[i:]
+	for _, op := range []struct {
+		token          string
+		regex, isEqual bool
+	}{{"=~", true, true}, {"!~", true, false}, {"!=", false, false}, {"=", false, true}} {
+		if strings.HasPrefix(rest, op.token) {
+			m.Value, m.IsRegex, m.IsEqual = rest[len(op.token):], op.regex, op.isEqual
+			break
+		}
+	}
+	if m.Value == "" {
+		return Matcher{}, fmt.Errorf("--silence-matcher %q: empty value", s)
+	}
+	if m.IsRegex {
+		if _, err := regexp.Compile(strings.ReplaceAll(m.Value, NodePlaceholder, "node")); err != nil {
+			return Matcher{}, fmt.Errorf("--silence-matcher %q: %w", s, err)
+		}
+	}
+	return m, nil
+}
+
+// ForNode returns m with NodePlaceholder replaced by node, quoted for regex
+// matchers.
+func (m Matcher) ForNode(node string) Matcher {
+	if m.IsRegex {
+		node = regexp.QuoteMeta(node)
+	}
+	m.Value = strings.ReplaceAll(m.Value, NodePlaceholder, node)
+	return m
+}
+
+type silence struct {
+	Matchers  []Matcher `json:"matchers"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	CreatedBy string    `json:"createdBy"`
+	Comment   string    `json:"comment"`
+}
+
+// Client talks to the Alertmanager v2 API.
+type Client struct {
+	// URL is the Alertmanager base URL. Basic auth credentials may be given
+	// in its user info.
+	URL       string
+	Matchers  []Matcher
+	Duration  time.Duration
+	CreatedBy string
+	HTTP      *http.Client
+}
+
+// Silence creates a silence of the node's alerts and returns its ID.
+func (c *Client) Silence(ctx context.Context, node, comment string) (string, error) {
+	s := silence{
+		StartsAt:  time.Now().UTC(),
+		CreatedBy: c.CreatedBy,
+		Comment:   comment,
+	}
+	s.EndsAt = s.StartsAt.Add(c.Duration)
+	for _, m := range c.Matchers {
+		s.Matchers = append(s.Matchers, m.ForNode(node))
+	}
+	body, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.do(ctx, http.MethodPost, "/api/v2/silences", body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var created struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", fmt.Errorf("decode Alertmanager response: %w", err)
+	}
+	if created.SilenceID == "" {
+		return "", fmt.Errorf("alertmanager returned no silence ID")
+	}
+	return created.SilenceID, nil
+}
+
+// Expire ends the silence with the given ID.
+func (c *Client) Expire(ctx context.Context, id string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/api/v2/silence/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	client := c.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.URL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("alertmanager returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,104 @@
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseMatcher(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Matcher
+		wantErr bool
+	}{
+		{spec: "node={node}", want: Matcher{Name: "node", Value: "{node}", IsEqual: true}},
+		{spec: "instance=~{node}(:[0-9]+)?", want: Matcher{Name: "instance", Value: "{node}(:[0-9]+)?", IsRegex: true, IsEqual: true}},
+		{spec: "severity!=info", want: Matcher{Name: "severity", Value: "info"}},
+		{spec: "job!~kube-.*", want: Matcher{Name: "job", Value: "kube-.*", IsRegex: true}},
+		{spec: "=value", wantErr: true},
+		{spec: "node=", wantErr: true},
+		{spec: "node", wantErr: true},
+		{spec: "instance=~({node}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseMatcher(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseMatcher() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcherForNode(t *testing.T) {
+	regex := Matcher{Name: "instance", Value: "{node}(:[0-9]+)?", IsRegex: true, IsEqual: true}
+	if got := regex.ForNode("worker-1.example.com").Value; got != `worker-1\.example\.com(:[0-9]+)?` {
+		t.Errorf("regex ForNode() = %q", got)
+	}
+	plain := Matcher{Name: "node", Value: "{node}", IsEqual: true}
+	if got := plain.ForNode("worker-1.example.com").Value; got != "worker-1.example.com" {
+		t.Errorf("plain ForNode() = %q", got)
+	}
+}
+
+func TestSilenceAndExpire(t *testing.T) {
+	var created silence
+	var expired string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v2/silences":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "am" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"silenceID":"5f3e"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v2/silence/5f3e":
+			expired = "5f3e"
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		URL:       "http://am:secret@" + srv.Listener.Addr().String() + "/",
+		Matchers:  []Matcher{{Name: "node", Value: NodePlaceholder, IsEqual: true}},
+		Duration:  time.Hour,
+		CreatedBy: "alice@laptop",
+	}
+	ctx := context.Background()
+	id, err := c.Silence(ctx, "worker-1", "rebooting worker-1")
+	if err != nil {
+		t.Fatalf("Silence() error = %v", err)
+	}
+	if id != "5f3e" {
+		t.Errorf("silence ID = %q, want 5f3e", id)
+	}
+	if len(created.Matchers) != 1 || created.Matchers[0].Value != "worker-1" || created.CreatedBy != "alice@laptop" {
+		t.Errorf("unexpected silence %+v", created)
+	}
+	if d := created.EndsAt.Sub(created.StartsAt); d != time.Hour {
+		t.Errorf("silence lasts %s, want 1h", d)
+	}
+
+	if err := c.Expire(ctx, id); err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	if expired != id {
+		t.Error("silence was not expired")
+	}
+	if err := c.Expire(ctx, "unknown"); err == nil {
+		t.Error("Expire() of an unknown silence should fail")
+	}
+}
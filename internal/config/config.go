@@ -1,10 +1,16 @@
 package config
 
 import (
-	"flag"
 	"fmt"
-	"os"
 	"strings"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/alertmanager"
+	"github.com/ayetkin/kubectl-reboot/internal/hooks"
+	"github.com/ayetkin/kubectl-reboot/internal/kube"
+	"github.com/ayetkin/kubectl-reboot/internal/window"
+	"github.com/spf13/pflag"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 )
 
 type Config struct {
@@ -15,17 +21,62 @@ type Config struct {
 	SSHOpts                    string
 	SSHHostTemplate            string
 	RebootCmd                  string
+	RebootMethod               string
+	PreRebootCmd               string
+	PostRebootCmd              string
+	RemoteCmdTimeoutSeconds    int
+	RemoteCmdFailure           string
 	DrainArgs                  string
 	TimeoutReadySeconds        int
 	PollIntervalSeconds        int
 	TimeoutBootIDSeconds       int
 	AllowUncordonWithoutReboot bool
-	KubeContext                string
-	KubeconfigPath             string
+	ForceUncordon              bool
+	KubeFlags                  *genericclioptions.ConfigFlags
 	DryRun                     bool
 	AllNodes                   bool
 	ExcludeControlPlane        bool
 	ExcludeNodes               []string // new
+	BatchSize                  int
+	MaxFailures                int
+	Canary                     int
+	Soak                       time.Duration
+	WindowSpec                 string
+	Window                     *window.Window
+	NodeEstimateSeconds        int
+	SkipRebootedWithin         time.Duration
+	CapacityCheck              string
+	CapacityTimeoutSeconds     int
+	HealthGateSpecs            []string
+	HealthGates                []HealthGate
+	GateTimeoutSeconds         int
+	HookSpecs                  []string
+	Hooks                      []hooks.Hook
+	HookTimeoutSeconds         int
+	WaitWorkloads              bool
+	TimeoutWorkloadsSeconds    int
+	Output                     string
+	ReportFile                 string
+	LogFormat                  string
+	LogLevel                   string
+	LogTimestamps              bool
+	OnFailure                  map[string]string
+	NodeOverrides              map[string]NodeOverride
+	MetricsAddr                string
+	MetricsTextfile            string
+	Webhooks                   []string
+	SlackWebhooks              []string
+	WebhookRetries             int
+	AlertmanagerURL            string
+	SilenceMatcherSpecs        []string
+	SilenceMatchers            []alertmanager.Matcher
+	SilenceDurationSeconds     int
+	LockNamespace              string
+	ForceLock                  bool
+	Yes                        bool
+	Interactive                bool
+	NoDashboard                bool
+	InitialDelay               time.Duration
 }
 
 const (
@@ -35,74 +86,265 @@ const (
 	DefaultReadyTimeout  = 180
 	DefaultPollInterval  = 10
 	DefaultBootIDTimeout = 300
+	DefaultBatchSize     = 1
+	DefaultMaxFailures   = 1
+	DefaultCapacityWait  = 600
+	DefaultWorkloadWait  = 600
+	DefaultRemoteCmdWait = 300
+	DefaultNodeEstimate  = 900
+	DefaultLogFormat     = "text"
+	DefaultLogLevel      = "info"
+	DefaultWebhookRetry  = 3
+	DefaultInitialDelay  = 5 * time.Second
+)
+
+// Values of --capacity-check.
+const (
+	CapacityCheckOff   = "off"
+	CapacityCheckFail  = "fail"
+	CapacityCheckPause = "pause"
 )
 
-func Parse() *Config {
-	cfg := &Config{}
-	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
-	defaultKubeconfig := os.Getenv("KUBECONFIG")
-	fs.StringVar(&cfg.File, "file", "", "read node names from file (one per line)")
-	fs.StringVar(&cfg.File, "f", "", "read node names from file (one per line)")
-	fs.StringVar(&cfg.SSHUser, "ssh-user", "", "SSH username")
-	fs.StringVar(&cfg.SSHUser, "u", "", "SSH username")
-	fs.StringVar(&cfg.SSHIdentityFile, "i", "", "SSH private key file")
-	fs.StringVar(&cfg.SSHOpts, "ssh-opts", DefaultSSHOpts, "SSH options")
-	fs.StringVar(&cfg.SSHHostTemplate, "ssh-host-template", "%s", "SSH host template (e.g., %s.example.com)")
-	fs.StringVar(&cfg.RebootCmd, "reboot-cmd", DefaultRebootCmd, "reboot command to execute")
-	fs.StringVar(&cfg.DrainArgs, "drain-args", DefaultDrainArgs, "kubectl drain arguments")
-	fs.IntVar(&cfg.TimeoutReadySeconds, "timeout-ready", DefaultReadyTimeout, "timeout waiting for node to become ready (seconds)")
-	fs.IntVar(&cfg.PollIntervalSeconds, "poll-interval", DefaultPollInterval, "polling interval (seconds)")
-	fs.IntVar(&cfg.TimeoutBootIDSeconds, "timeout-bootid", DefaultBootIDTimeout, "timeout waiting for boot ID change (seconds)")
-	fs.BoolVar(&cfg.AllowUncordonWithoutReboot, "allow-uncordon-without-reboot", false, "allow uncordon even if reboot verification fails")
-	fs.BoolVar(&cfg.AllNodes, "all", false, "restart all nodes in the cluster")
-	fs.BoolVar(&cfg.ExcludeControlPlane, "exclude-control-plane", false, "exclude control plane nodes when using --all")
-	fs.StringVar(&cfg.KubeContext, "context", "", "kubeconfig context to use")
-	fs.StringVar(&cfg.KubeconfigPath, "kubeconfig", defaultKubeconfig, "path to kubeconfig file")
-	fs.BoolVar(&cfg.DryRun, "dry-run", false, "show what would be done without executing")
-	var excludeNodesRaw string
-	fs.StringVar(&excludeNodesRaw, "exclude-nodes", "", "comma-separated node names to exclude (e.g. node1,node2)")
-
-	for _, a := range os.Args[1:] {
-		if a == "-h" || a == "--help" {
-			fmt.Fprintf(os.Stderr, `k8s-restart - Kubernetes Node Restart Tool
-
-DESCRIPTION:
-    Safely restart Kubernetes nodes by draining pods, rebooting via SSH,
-    verifying the reboot, and uncordoning the nodes.
-
-USAGE:
-    k8s-restart [OPTIONS] [NODE_NAMES...]
-
-EXAMPLES:
-    # Restart specific nodes
-    k8s-restart node1 node2
-
-    # Restart all worker nodes (dry-run)
-    k8s-restart --all --exclude-control-plane --dry-run
-
-    # Restart nodes from file
-    k8s-restart -f nodes.txt
-
-    # Custom SSH settings
-    k8s-restart -u myuser -i ~/.ssh/mykey node1
-
-OPTIONS:
-`)
-			fs.PrintDefaults()
-			os.Exit(0)
+// Values of --remote-cmd-failure.
+const (
+	RemoteCmdAbort = "abort"
+	RemoteCmdWarn  = "warn"
+)
+
+// New returns a Config with the standard kubectl connection flags prepared.
+// Call the Add*Flags methods to register the flags a command needs, then
+// Complete once they have been parsed.
+func New() *Config {
+	// Commands without --batch-size act on one node at a time.
+	cfg := &Config{BatchSize: DefaultBatchSize}
+	// Nodes are cluster-scoped, so --namespace is not registered.
+	cfg.KubeFlags = genericclioptions.NewConfigFlags(true)
+	cfg.KubeFlags.Namespace = nil
+	return cfg
+}
+
+// AddKubeFlags registers the standard kubectl connection flags (--kubeconfig,
+// --context, --cluster, --user, --as, --token, --server, --request-timeout, ...).
+func (c *Config) AddKubeFlags(fs *pflag.FlagSet) {
+	c.KubeFlags.AddFlags(fs)
+}
+
+// AddLogFlags registers the flags controlling log output.
+func (c *Config) AddLogFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.LogFormat, "log-format", DefaultLogFormat, "log format: text, json or logfmt")
+	fs.StringVar(&c.LogLevel, "log-level", DefaultLogLevel, "log level: debug, info, warn or error")
+	fs.BoolVar(&c.LogTimestamps, "log-timestamps", false, "include timestamps in log output")
+}
+
+// AddTargetFlags registers the flags that select and order the nodes to act on.
+func (c *Config) AddTargetFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.File, "file", "f", "", "read node names from file (one per line, optionally followed by key=value overrides)")
+	fs.BoolVar(&c.AllNodes, "all", false, "restart all nodes in the cluster")
+	fs.BoolVar(&c.ExcludeControlPlane, "exclude-control-plane", false, "exclude control plane nodes when using --all")
+	fs.StringSliceVar(&c.ExcludeNodes, "exclude-nodes", nil, "comma-separated node names to exclude (e.g. node1,node2)")
+}
+
+// AddBatchFlags registers the flags that split the nodes into batches and
+// hold the rest back behind a canary.
+func (c *Config) AddBatchFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&c.BatchSize, "batch-size", DefaultBatchSize, "number of nodes to restart concurrently in each batch")
+	fs.IntVar(&c.Canary, "canary", 0, "restart the first N nodes on their own, then check they stay healthy for --soak before starting the rest")
+	fs.DurationVar(&c.Soak, "soak", 0, "how long the --canary nodes must stay Ready without new CrashLoopBackOff pods (e.g. 15m); 0 checks once")
+}
+
+// AddRunFlags registers the flags deciding when a run gives up on the
+// remaining nodes.
+func (c *Config) AddRunFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&c.MaxFailures, "max-failures", DefaultMaxFailures, "abort the run once this many nodes have failed; 0 never aborts")
+}
+
+// AddRebootFlags registers the flags controlling how each node is restarted.
+func (c *Config) AddRebootFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.SSHUser, "ssh-user", "u", "", "SSH username")
+	fs.StringVarP(&c.SSHIdentityFile, "identity-file", "i", "", "SSH private key file")
+	fs.StringVar(&c.SSHOpts, "ssh-opts", DefaultSSHOpts, "SSH options")
+	fs.StringVar(&c.SSHHostTemplate, "ssh-host-template", "%s", "SSH host template (e.g., %s.example.com)")
+	fs.StringVar(&c.RebootCmd, "reboot-cmd", DefaultRebootCmd, "reboot command to execute")
+	fs.StringVar(&c.RebootMethod, "reboot-method", RebootMethodSSH, "how to trigger the reboot: ssh, or none to wait for an out-of-band reboot")
+	fs.StringVar(&c.PreRebootCmd, "pre-reboot-cmd", "", "command to run on the node over SSH after the drain, before the reboot")
+	fs.StringVar(&c.PostRebootCmd, "post-reboot-cmd", "", "command to run on the node over SSH once it is Ready again, before uncordon")
+	fs.IntVar(&c.RemoteCmdTimeoutSeconds, "timeout-remote-cmd", DefaultRemoteCmdWait, "timeout for --pre-reboot-cmd and --post-reboot-cmd (seconds)")
+	fs.StringVar(&c.RemoteCmdFailure, "remote-cmd-failure", RemoteCmdAbort, "what a failed --pre-reboot-cmd or --post-reboot-cmd does: abort the node, or warn and continue")
+	fs.StringVar(&c.DrainArgs, "drain-args", DefaultDrainArgs, "kubectl drain arguments")
+	fs.IntVar(&c.TimeoutReadySeconds, "timeout-ready", DefaultReadyTimeout, "timeout waiting for node to become ready (seconds)")
+	fs.IntVar(&c.PollIntervalSeconds, "poll-interval", DefaultPollInterval, "polling interval (seconds)")
+	fs.IntVar(&c.TimeoutBootIDSeconds, "timeout-bootid", DefaultBootIDTimeout, "timeout waiting for boot ID change (seconds)")
+	fs.BoolVar(&c.AllowUncordonWithoutReboot, "allow-uncordon-without-reboot", false, "allow uncordon even if reboot verification fails")
+	fs.StringArrayVar(&c.HealthGateSpecs, "health-gate", nil, "gate to pass before uncordon, as KIND[:ARG][@TIMEOUT]: daemonsets, condition:TYPE=STATUS or pods:SELECTOR (repeatable)")
+	fs.IntVar(&c.GateTimeoutSeconds, "timeout-gates", DefaultGateTimeout, "default timeout for each --health-gate (seconds)")
+	fs.StringArrayVar(&c.HookSpecs, "hook", nil, "local executable to run as EVENT=PATH, EVENT being before-cordon, after-drain, after-reboot, before-uncordon or on-failure (repeatable)")
+	fs.IntVar(&c.HookTimeoutSeconds, "timeout-hooks", hooks.DefaultTimeout, "timeout for each --hook (seconds)")
+	fs.BoolVar(&c.WaitWorkloads, "wait-workloads", false, "after the drain and again after uncordon, wait until the Deployments, StatefulSets and ReplicaSets of evicted pods are fully available")
+	fs.IntVar(&c.TimeoutWorkloadsSeconds, "timeout-workloads", DefaultWorkloadWait, "timeout for each --wait-workloads wait (seconds)")
+	fs.BoolVar(&c.ForceUncordon, "force-uncordon", false, "uncordon nodes at the end even if they were cordoned by someone else before the run")
+	fs.StringVar(&c.CapacityCheck, "capacity-check", CapacityCheckOff, "before draining, check the other schedulable nodes can absorb the evicted pods: off, fail, or pause until capacity frees up")
+	fs.IntVar(&c.CapacityTimeoutSeconds, "capacity-timeout", DefaultCapacityWait, "how long --capacity-check=pause waits for capacity before failing the node (seconds)")
+	fs.StringToStringVar(&c.OnFailure, "on-failure", nil, "what to do with a node whose restart failed, per phase: leave-cordoned, uncordon or taint (e.g. drain=taint,wait-ready=uncordon)")
+	fs.StringVar(&c.WindowSpec, "window", "", `only restart nodes inside this maintenance window, as "[DAYS] HH:MM-HH:MM [TIMEZONE]", several separated by ";" (e.g. "Mon-Fri 01:00-05:00 Europe/Istanbul")`)
+	fs.IntVar(&c.NodeEstimateSeconds, "node-estimate", DefaultNodeEstimate, "estimated time to restart a batch, used with --window until a batch has completed (seconds)")
+	fs.DurationVar(&c.SkipRebootedWithin, "skip-rebooted-within", 0, "skip nodes kubectl-reboot restarted within this duration (e.g. 24h), to resume an interrupted run")
+	fs.BoolVar(&c.DryRun, "dry-run", false, "show what would be done without executing")
+}
+
+// AddReportFlags registers the flags requesting a machine-readable run report.
+func (c *Config) AddReportFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.Output, "output", "o", "", "print a run report to stdout at the end: json or yaml")
+	fs.StringVar(&c.ReportFile, "report", "", "write the run report to this file (YAML for .yaml/.yml, otherwise JSON unless -o is set)")
+}
+
+// AddMetricsFlags registers the flags exposing Prometheus metrics.
+func (c *Config) AddMetricsFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.MetricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address (e.g. :9090) while the run is in progress")
+	fs.StringVar(&c.MetricsTextfile, "metrics-textfile", "", "write Prometheus metrics to this file for the node_exporter textfile collector")
+}
+
+// AddLockFlags registers the flags controlling the cluster-wide run lock.
+func (c *Config) AddLockFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.LockNamespace, "lock-namespace", kube.DefaultLockNamespace, "namespace of the Lease that prevents concurrent runs against the cluster")
+	fs.BoolVar(&c.ForceLock, "force-lock", false, "take over the run lock even if another run holds it")
+}
+
+// AddConfirmFlags registers the flags controlling the confirmation before a
+// run starts, the pauses between batches and the progress dashboard.
+func (c *Config) AddConfirmFlags(fs *pflag.FlagSet) {
+	fs.BoolVarP(&c.Yes, "yes", "y", false, "start without showing the plan and asking for confirmation on a terminal")
+	fs.BoolVar(&c.Interactive, "interactive", false, "after each batch, show its nodes' phase timings and ask whether to continue, skip the next batch, retry failed nodes or abort")
+	fs.BoolVar(&c.NoDashboard, "no-dashboard", false, "log progress line by line instead of showing the live dashboard on a terminal")
+	fs.DurationVar(&c.InitialDelay, "initial-delay", DefaultInitialDelay, "wait this long before the first node is started, leaving time to interrupt")
+}
+
+// AddNotifyFlags registers the flags configuring webhook notifications.
+func (c *Config) AddNotifyFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVar(&c.Webhooks, "webhook", nil, "POST JSON notifications on run start, node success/failure and run completion to this URL (repeatable)")
+	fs.StringArrayVar(&c.SlackWebhooks, "slack-webhook", nil, "post Slack-formatted notifications to this incoming webhook URL (repeatable)")
+	fs.IntVar(&c.WebhookRetries, "webhook-retries", DefaultWebhookRetry, "retries for a failed webhook delivery")
+}
+
+// AddSilenceFlags registers the flags silencing node alerts in Alertmanager.
+func (c *Config) AddSilenceFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.AlertmanagerURL, "alertmanager-url", "", "silence each node's alerts in this Alertmanager from just before the reboot until the node is Ready")
+	fs.StringArrayVar(&c.SilenceMatcherSpecs, "silence-matcher", []string{alertmanager.DefaultMatcher}, "label matcher of the silence, {node} being replaced by the node name (repeatable)")
+	fs.IntVar(&c.SilenceDurationSeconds, "silence-duration", alertmanager.DefaultDuration, "lifetime of a silence should the run not expire it (seconds)")
+}
+
+// Complete records the positional node names and validates the parsed flags.
+func (c *Config) Complete(args []string) error {
+	c.Nodes = append([]string(nil), args...)
+	c.ExcludeNodes = trimEmpty(c.ExcludeNodes)
+	if c.RebootMethod != "" {
+		if err := ValidateRebootMethod(c.RebootMethod); err != nil {
+			return err
 		}
 	}
-	if err := fs.Parse(os.Args[1:]); err != nil {
-		os.Exit(2)
+	if c.Output != "" && c.Output != "json" && c.Output != "yaml" {
+		return fmt.Errorf("--output must be json or yaml, got %q", c.Output)
+	}
+	switch c.CapacityCheck {
+	case "", CapacityCheckOff, CapacityCheckFail, CapacityCheckPause:
+	default:
+		return fmt.Errorf("--capacity-check must be %s, %s or %s, got %q", CapacityCheckOff, CapacityCheckFail, CapacityCheckPause, c.CapacityCheck)
+	}
+	switch c.RemoteCmdFailure {
+	case "", RemoteCmdAbort, RemoteCmdWarn:
+	default:
+		return fmt.Errorf("--remote-cmd-failure must be %s or %s, got %q", RemoteCmdAbort, RemoteCmdWarn, c.RemoteCmdFailure)
 	}
-	cfg.Nodes = fs.Args()
-	if excludeNodesRaw != "" {
-		for _, p := range strings.Split(excludeNodesRaw, ",") {
-			p = strings.TrimSpace(p)
-			if p != "" {
-				cfg.ExcludeNodes = append(cfg.ExcludeNodes, p)
-			}
+	c.Window = nil
+	if c.WindowSpec != "" {
+		w, err := window.Parse(c.WindowSpec)
+		if err != nil {
+			return err
 		}
+		c.Window = w
 	}
-	return cfg
+	c.HealthGates = nil
+	for _, spec := range c.HealthGateSpecs {
+		g, err := ParseHealthGate(spec, time.Duration(c.GateTimeoutSeconds)*time.Second)
+		if err != nil {
+			return err
+		}
+		c.HealthGates = append(c.HealthGates, g)
+	}
+	c.SilenceMatchers = nil
+	for _, spec := range c.SilenceMatcherSpecs {
+		m, err := alertmanager.ParseMatcher(spec)
+		if err != nil {
+			return err
+		}
+		c.SilenceMatchers = append(c.SilenceMatchers, m)
+	}
+	c.Hooks = nil
+	for _, spec := range c.HookSpecs {
+		h, err := hooks.Parse(spec)
+		if err != nil {
+			return err
+		}
+		c.Hooks = append(c.Hooks, h)
+	}
+	if err := validateOnFailure(c.OnFailure); err != nil {
+		return err
+	}
+	if c.MaxFailures < 0 {
+		return fmt.Errorf("--max-failures must not be negative, got %d", c.MaxFailures)
+	}
+	if c.WebhookRetries < 0 {
+		return fmt.Errorf("--webhook-retries must not be negative, got %d", c.WebhookRetries)
+	}
+	if c.BatchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1, got %d", c.BatchSize)
+	}
+	if c.Canary < 0 {
+		return fmt.Errorf("--canary must not be negative, got %d", c.Canary)
+	}
+	if c.Soak < 0 {
+		return fmt.Errorf("--soak must not be negative, got %s", c.Soak)
+	}
+	if c.InitialDelay < 0 {
+		return fmt.Errorf("--initial-delay must not be negative, got %s", c.InitialDelay)
+	}
+	return nil
+}
+
+// Batches splits the target nodes into consecutive groups of BatchSize. The
+// --canary nodes are split on their own, so that no batch mixes canary and
+// other nodes.
+func (c *Config) Batches() [][]string {
+	canary := c.CanaryNodes()
+	return append(splitBatches(canary, c.BatchSize), splitBatches(c.Nodes[len(canary):], c.BatchSize)...)
+}
+
+// CanaryNodes returns the first --canary target nodes.
+func (c *Config) CanaryNodes() []string {
+	return c.Nodes[:min(max(c.Canary, 0), len(c.Nodes))]
+}
+
+func splitBatches(nodes []string, size int) [][]string {
+	if size < 1 {
+		size = 1
+	}
+	var batches [][]string
+	for i := 0; i < len(nodes); i += size {
+		end := i + size
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		batches = append(batches, nodes[i:end])
+	}
+	return batches
+}
+
+func trimEmpty(in []string) []string {
+	var out []string
+	for _, p := range in {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
 }
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// What to do with a node whose restart failed.
+const (
+	OnFailureLeaveCordoned = "leave-cordoned"
+	OnFailureUncordon      = "uncordon"
+	OnFailureTaint         = "taint"
+)
+
+// defaultOnFailure gives the policy for each phase whose failure stops a
+// node's restart. Before the reboot is sent the node is healthy, so it is
+// made schedulable again; after it, the node may be broken and stays
+// cordoned for inspection.
+var defaultOnFailure = map[string]string{
+	"capacity-check":       OnFailureUncordon,
+	"hook-before-cordon":   OnFailureUncordon,
+	"cordon":               OnFailureUncordon,
+	"drain":                OnFailureUncordon,
+	"wait-workloads":       OnFailureUncordon,
+	"pre-reboot":           OnFailureUncordon,
+	"silence":              OnFailureUncordon,
+	"wait-boot-id":         OnFailureLeaveCordoned,
+	"wait-ready":           OnFailureLeaveCordoned,
+	"post-reboot":          OnFailureLeaveCordoned,
+	"health-gates":         OnFailureLeaveCordoned,
+	"hook-before-uncordon": OnFailureLeaveCordoned,
+}
+
+// FailurePolicy returns the --on-failure policy for a failure in phase.
+func (c *Config) FailurePolicy(phase string) string {
+	if p, ok := c.OnFailure[phase]; ok {
+		return p
+	}
+	if p, ok := defaultOnFailure[phase]; ok {
+		return p
+	}
+	return OnFailureLeaveCordoned
+}
+
+func validateOnFailure(policies map[string]string) error {
+	for phase, policy := range policies {
+		if _, ok := defaultOnFailure[phase]; !ok {
+			return fmt.Errorf("--on-failure: unknown phase %q (want one of %s)", phase, strings.Join(failurePhases(), ", "))
+		}
+		switch policy {
+		case OnFailureLeaveCordoned, OnFailureUncordon, OnFailureTaint:
+		default:
+			return fmt.Errorf("--on-failure: unknown policy %q for %s (want %s, %s or %s)", policy, phase, OnFailureLeaveCordoned, OnFailureUncordon, OnFailureTaint)
+		}
+	}
+	return nil
+}
+
+func failurePhases() []string {
+	phases := make([]string, 0, len(defaultOnFailure))
+	for p := range defaultOnFailure {
+		phases = append(phases, p)
+	}
+	sort.Strings(phases)
+	return phases
+}
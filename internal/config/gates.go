@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of health gate evaluated before a rebooted node is uncordoned.
+const (
+	GateDaemonSets = "daemonsets"
+	GateCondition  = "condition"
+	GatePods       = "pods"
+)
+
+const DefaultGateTimeout = 300
+
+// HealthGate is a parsed --health-gate.
+type HealthGate struct {
+	Kind string
+	// Arg is TYPE=STATUS for condition gates and a label selector for pod
+	// gates.
+	Arg     string
+	Timeout time.Duration
+}
+
+func (g HealthGate) String() string {
+	if g.Arg == "" {
+		return g.Kind
+	}
+	return g.Kind + ":" + g.Arg
+}
+
+// ParseHealthGate parses KIND[:ARG][@TIMEOUT], for example
+//
+//	daemonsets@5m
+//	condition:MemoryPressure=False
+//	pods:k8s-app=cilium@10m
+//
+// Gates without a timeout use def.
+func ParseHealthGate(s string, def time.Duration) (HealthGate, error) {
+	spec, timeout, hasTimeout := strings.Cut(strings.TrimSpace(s), "@")
+	kind, arg, _ := strings.Cut(spec, ":")
+	g := HealthGate{Kind: kind, Arg: arg, Timeout: def}
+	if hasTimeout {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return HealthGate{}, fmt.Errorf("--health-gate %q: invalid timeout %q", s, timeout)
+		}
+		g.Timeout = d
+	}
+	switch kind {
+	case GateDaemonSets:
+		if arg != "" {
+			return HealthGate{}, fmt.Errorf("--health-gate %q: %s takes no argument", s, kind)
+		}
+	case GateCondition:
+		typ, status, ok := strings.Cut(arg, "=")
+		if !ok || typ == "" {
+			return HealthGate{}, fmt.Errorf("--health-gate %q: want condition:TYPE=True|False|Unknown", s)
+		}
+		switch status {
+		case "True", "False", "Unknown":
+		default:
+			return HealthGate{}, fmt.Errorf("--health-gate %q: condition status must be True, False or Unknown", s)
+		}
+	case GatePods:
+		if arg == "" {
+			return HealthGate{}, fmt.Errorf("--health-gate %q: want pods:LABEL_SELECTOR", s)
+		}
+	default:
+		return HealthGate{}, fmt.Errorf("--health-gate %q: unknown gate %q (want %s, %s or %s)", s, kind, GateDaemonSets, GateCondition, GatePods)
+	}
+	return g, nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHealthGate(t *testing.T) {
+	def := 5 * time.Minute
+	tests := []struct {
+		spec    string
+		want    HealthGate
+		wantErr bool
+	}{
+		{spec: "daemonsets", want: HealthGate{Kind: GateDaemonSets, Timeout: def}},
+		{spec: "daemonsets@10m", want: HealthGate{Kind: GateDaemonSets, Timeout: 10 * time.Minute}},
+		{spec: "condition:MemoryPressure=False", want: HealthGate{Kind: GateCondition, Arg: "MemoryPressure=False", Timeout: def}},
+		{spec: "pods:k8s-app=cilium,tier!=test@90s", want: HealthGate{Kind: GatePods, Arg: "k8s-app=cilium,tier!=test", Timeout: 90 * time.Second}},
+		{spec: "daemonsets:extra", wantErr: true},
+		{spec: "condition:MemoryPressure", wantErr: true},
+		{spec: "condition:MemoryPressure=no", wantErr: true},
+		{spec: "pods", wantErr: true},
+		{spec: "pods:app=x@soon", wantErr: true},
+		{spec: "http:example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseHealthGate(tt.spec, def)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHealthGate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHealthGate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
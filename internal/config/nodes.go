@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	RebootMethodSSH  = "ssh"
+	RebootMethodNone = "none"
+)
+
+// NodeOverride holds per-node settings from an extended nodes file line.
+// Empty or zero fields fall back to the global flags.
+type NodeOverride struct {
+	SSHHost              string
+	SSHUser              string
+	RebootCmd            string
+	RebootMethod         string
+	PreRebootCmd         string
+	PostRebootCmd        string
+	TimeoutReadySeconds  int
+	TimeoutBootIDSeconds int
+}
+
+// NodeSettings is the effective configuration for a single node after
+// applying its overrides on top of the global flags.
+type NodeSettings struct {
+	SSHHost              string // explicit host; takes precedence over SSHHostTemplate
+	SSHHostTemplate      string
+	SSHUser              string
+	RebootCmd            string
+	RebootMethod         string
+	PreRebootCmd         string
+	PostRebootCmd        string
+	TimeoutReadySeconds  int
+	TimeoutBootIDSeconds int
+}
+
+// ForNode returns the settings to use for the given node.
+func (c *Config) ForNode(name string) NodeSettings {
+	s := NodeSettings{
+		SSHHostTemplate:      c.SSHHostTemplate,
+		SSHUser:              c.SSHUser,
+		RebootCmd:            c.RebootCmd,
+		RebootMethod:         c.RebootMethod,
+		PreRebootCmd:         c.PreRebootCmd,
+		PostRebootCmd:        c.PostRebootCmd,
+		TimeoutReadySeconds:  c.TimeoutReadySeconds,
+		TimeoutBootIDSeconds: c.TimeoutBootIDSeconds,
+	}
+	if s.RebootMethod == "" {
+		s.RebootMethod = RebootMethodSSH
+	}
+	o, ok := c.NodeOverrides[name]
+	if !ok {
+		return s
+	}
+	s.SSHHost = o.SSHHost
+	if o.SSHUser != "" {
+		s.SSHUser = o.SSHUser
+	}
+	if o.RebootCmd != "" {
+		s.RebootCmd = o.RebootCmd
+	}
+	if o.RebootMethod != "" {
+		s.RebootMethod = o.RebootMethod
+	}
+	if o.PreRebootCmd != "" {
+		s.PreRebootCmd = o.PreRebootCmd
+	}
+	if o.PostRebootCmd != "" {
+		s.PostRebootCmd = o.PostRebootCmd
+	}
+	if o.TimeoutReadySeconds > 0 {
+		s.TimeoutReadySeconds = o.TimeoutReadySeconds
+	}
+	if o.TimeoutBootIDSeconds > 0 {
+		s.TimeoutBootIDSeconds = o.TimeoutBootIDSeconds
+	}
+	return s
+}
+
+// ValidateRebootMethod reports whether m is a supported reboot method.
+func ValidateRebootMethod(m string) error {
+	switch m {
+	case RebootMethodSSH, RebootMethodNone:
+		return nil
+	}
+	return fmt.Errorf("unknown reboot method %q (want %s or %s)", m, RebootMethodSSH, RebootMethodNone)
+}
+
+// ParseNodeLine parses one line of a nodes file. The first field is the node
+// name, optionally followed by key=value overrides:
+//
+//	node1 host=10.0.0.5 user=ubuntu reboot-cmd="sudo shutdown -r now" timeout-ready=300
+//
+// Values containing spaces may be wrapped in single or double quotes.
+func ParseNodeLine(line string) (string, *NodeOverride, error) {
+	fields, err := splitFields(line)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("empty line")
+	}
+	name := fields[0]
+	if strings.Contains(name, "=") {
+		return "", nil, fmt.Errorf("line must start with a node name, got %q", name)
+	}
+	if len(fields) == 1 {
+		return name, nil, nil
+	}
+
+	o := &NodeOverride{}
+	for _, f := range fields[1:] {
+		key, val, ok := strings.Cut(f, "=")
+		if !ok || key == "" {
+			return "", nil, fmt.Errorf("node %s: expected key=value, got %q", name, f)
+		}
+		switch key {
+		case "host":
+			o.SSHHost = val
+		case "user":
+			o.SSHUser = val
+		case "reboot-cmd":
+			o.RebootCmd = val
+		case "pre-reboot-cmd":
+			o.PreRebootCmd = val
+		case "post-reboot-cmd":
+			o.PostRebootCmd = val
+		case "reboot-method":
+			if err := ValidateRebootMethod(val); err != nil {
+				return "", nil, fmt.Errorf("node %s: %w", name, err)
+			}
+			o.RebootMethod = val
+		case "timeout-ready", "timeout-bootid":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return "", nil, fmt.Errorf("node %s: %s must be a positive number of seconds, got %q", name, key, val)
+			}
+			if key == "timeout-ready" {
+				o.TimeoutReadySeconds = n
+			} else {
+				o.TimeoutBootIDSeconds = n
+			}
+		default:
+			return "", nil, fmt.Errorf("node %s: unknown key %q", name, key)
+		}
+	}
+	return name, o, nil
+}
+
+// splitFields splits s on whitespace, keeping quoted sections together and
+// stripping the quotes.
+func splitFields(s string) ([]string, error) {
+	var fields []string
+	var cur strings.Builder
+	var quote rune
+	inField := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t':
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
+}
@@ -0,0 +1,319 @@
+// Package dashboard draws a live view of a run on a terminal: one row per
+// node with its current phase, elapsed time and pods left to evict, and a
+// summary table once the run ends.
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+)
+
+// DefaultInterval is how often the dashboard is redrawn.
+const DefaultInterval = time.Second
+
+// statusPending marks target nodes the run has not reached yet.
+const statusPending report.Status = "pending"
+
+// logLines is how many of the most recent log lines are shown below the
+// node table.
+const logLines = 5
+
+// Dashboard redraws the progress of a run in place on Out. Log output
+// written to it is passed on to Log as it arrives, and its last few lines
+// are shown under the node table.
+type Dashboard struct {
+	Out io.Writer
+	// Log receives every log line written to the dashboard right away, so
+	// that none is lost if the run dies. It must not be the terminal Out
+	// draws on.
+	Log    io.Writer
+	Report *report.Report
+	// Nodes are the target nodes in run order; those not yet started are
+	// shown as pending.
+	Nodes []string
+	// PodsLeft, if set, counts the pods still to be evicted from a node
+	// being drained.
+	PodsLeft func(ctx context.Context, node string) (int, error)
+	Interval time.Duration
+
+	mu       sync.Mutex
+	recent   []string // last logLines complete log lines
+	partial  []byte   // log output after the last newline
+	podsLeft map[string]int
+	lines    int // lines drawn by the last frame
+	styles   styles
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type styles struct {
+	title, dim, ok, failed, running lipgloss.Style
+	cell, header                    lipgloss.Style
+}
+
+func newStyles(out io.Writer) styles {
+	r := lipgloss.NewRenderer(out)
+	return styles{
+		title:   r.NewStyle().Bold(true),
+		dim:     r.NewStyle().Faint(true),
+		ok:      r.NewStyle().Foreground(lipgloss.Color("2")),
+		failed:  r.NewStyle().Foreground(lipgloss.Color("1")),
+		running: r.NewStyle().Foreground(lipgloss.Color("3")),
+		cell:    r.NewStyle().Padding(0, 1),
+		header:  r.NewStyle().Padding(0, 1).Bold(true),
+	}
+}
+
+// Write passes log output on to Log and keeps its last lines for display.
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.partial = append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(d.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if len(d.recent) == logLines {
+			d.recent = append(d.recent[:0], d.recent[1:]...)
+		}
+		d.recent = append(d.recent, string(d.partial[:i]))
+		d.partial = d.partial[i+1:]
+	}
+	if d.Log == nil {
+		return len(p), nil
+	}
+	return d.Log.Write(p)
+}
+
+// Start draws the dashboard every Interval until Stop is called.
+func (d *Dashboard) Start() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.styles = newStyles(d.Out)
+	d.podsLeft = map[string]int{}
+	stop, done := make(chan struct{}), make(chan struct{})
+	d.stop, d.done = stop, done
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			d.refreshPodsLeft()
+			d.draw(time.Now())
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// Stop erases the live view and prints the summary table. Only the first
+// call after Start does anything; it reports whether this call did.
+func (d *Dashboard) Stop() bool {
+	d.mu.Lock()
+	stop, done := d.stop, d.done
+	d.stop = nil
+	d.mu.Unlock()
+	if stop == nil {
+		return false
+	}
+	close(stop)
+	<-done
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	fmt.Fprintln(d.Out, d.summary())
+	return true
+}
+
+func (d *Dashboard) draw(now time.Time) {
+	frame := d.Frame(now)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clear()
+	fmt.Fprintln(d.Out, frame)
+	d.lines = strings.Count(frame, "\n") + 1
+}
+
+// clear erases the last frame by moving the cursor back to its first line.
+func (d *Dashboard) clear() {
+	if d.lines > 0 {
+		fmt.Fprintf(d.Out, "\x1b[%dA\x1b[J", d.lines)
+		d.lines = 0
+	}
+}
+
+// refreshPodsLeft counts the pods left on the nodes being drained.
+func (d *Dashboard) refreshPodsLeft() {
+	if d.PodsLeft == nil {
+		return
+	}
+	counts := map[string]int{}
+	for _, np := range d.Report.Progress() {
+		if np.Status != report.StatusRunning || np.Phase != report.PhaseDrain {
+			continue
+		}
+		if n, err := d.PodsLeft(context.Background(), np.Name); err == nil {
+			counts[np.Name] = n
+		}
+	}
+	d.mu.Lock()
+	d.podsLeft = counts
+	d.mu.Unlock()
+}
+
+// Frame renders the live view at now.
+func (d *Dashboard) Frame(now time.Time) string {
+	progress := d.progress()
+	var done, failed int
+	t := d.table(lipgloss.HiddenBorder()).Headers("NODE", "STATUS", "PHASE", "ELAPSED", "PODS LEFT")
+	d.mu.Lock()
+	podsLeft := d.podsLeft
+	d.mu.Unlock()
+	for _, np := range progress {
+		switch np.Status {
+		case report.StatusSucceeded:
+			done++
+		case report.StatusFailed:
+			done++
+			failed++
+		}
+		left := "-"
+		if n, ok := podsLeft[np.Name]; ok && np.Phase == report.PhaseDrain && np.Status == report.StatusRunning {
+			left = strconv.Itoa(n)
+		}
+		t.Row(np.Name, d.status(np.Status), phaseOf(np), elapsed(np, now), left)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %s\n", d.styles.title.Render("kubectl-reboot"), d.styles.dim.Render(fmt.Sprintf("run %s, %s elapsed", valueOr(d.Report.RunID, "-"), now.Sub(d.Report.StartedAt).Round(time.Second))))
+	fmt.Fprintf(&b, "%d/%d nodes done, %d failed\n", done, len(progress), failed)
+	b.WriteString(t.Render())
+	if recent := d.recentLogs(); len(recent) > 0 {
+		b.WriteString("\n" + d.styles.dim.Render(strings.Join(recent, "\n")))
+	}
+	return b.String()
+}
+
+// summary renders the final table of every node and the run totals.
+func (d *Dashboard) summary() string {
+	t := d.table(lipgloss.NormalBorder()).Headers("NODE", "STATUS", "DURATION", "PODS EVICTED", "ERROR")
+	var succeeded, failed, other int
+	for _, np := range d.progress() {
+		switch np.Status {
+		case report.StatusSucceeded:
+			succeeded++
+		case report.StatusFailed:
+			failed++
+		default:
+			other++
+		}
+		duration := "-"
+		if np.StartedAt != nil && np.FinishedAt != nil {
+			duration = np.FinishedAt.Sub(*np.StartedAt).Round(time.Second).String()
+		}
+		evicted := "-"
+		if np.Status != statusPending {
+			evicted = strconv.Itoa(np.Evicted)
+		}
+		t.Row(np.Name, d.status(np.Status), duration, evicted, valueOr(np.Error, "-"))
+	}
+	return fmt.Sprintf("%s\n%d succeeded, %d failed, %d not restarted", t.Render(), succeeded, failed, other)
+}
+
+// table returns an empty table with a space either side of each cell.
+func (d *Dashboard) table(border lipgloss.Border) *table.Table {
+	return table.New().Border(border).StyleFunc(func(row, _ int) lipgloss.Style {
+		if row == table.HeaderRow {
+			return d.styles.header
+		}
+		return d.styles.cell
+	})
+}
+
+// progress returns the report's nodes in target order, with the target
+// nodes not yet in the report as pending.
+func (d *Dashboard) progress() []report.NodeProgress {
+	nodes := d.Report.Progress()
+	byName := map[string]report.NodeProgress{}
+	for _, np := range nodes {
+		byName[np.Name] = np
+	}
+	out := make([]report.NodeProgress, 0, len(d.Nodes))
+	seen := map[string]bool{}
+	for _, n := range d.Nodes {
+		np, ok := byName[n]
+		if !ok {
+			np = report.NodeProgress{Name: n, Status: statusPending}
+		}
+		out = append(out, np)
+		seen[n] = true
+	}
+	for _, np := range nodes {
+		if !seen[np.Name] {
+			out = append(out, np)
+		}
+	}
+	return out
+}
+
+func (d *Dashboard) status(s report.Status) string {
+	switch s {
+	case report.StatusSucceeded:
+		return d.styles.ok.Render(string(s))
+	case report.StatusFailed:
+		return d.styles.failed.Render(string(s))
+	case report.StatusRunning:
+		return d.styles.running.Render(string(s))
+	}
+	return d.styles.dim.Render(string(s))
+}
+
+// recentLogs returns the last few complete log lines.
+func (d *Dashboard) recentLogs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.recent...)
+}
+
+func phaseOf(np report.NodeProgress) string {
+	if np.Status != report.StatusRunning || np.Phase == "" {
+		return "-"
+	}
+	return string(np.Phase)
+}
+
+func elapsed(np report.NodeProgress, now time.Time) string {
+	if np.StartedAt == nil {
+		return "-"
+	}
+	end := now
+	if np.FinishedAt != nil {
+		end = *np.FinishedAt
+	}
+	return end.Sub(*np.StartedAt).Round(time.Second).String()
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+)
+
+// row returns the fields of the line in out that starts with node.
+func row(out, node string) []string {
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.FieldsFunc(line, func(r rune) bool { return r == '│' || r == ' ' })
+		if len(f) > 0 && f[0] == node {
+			return f
+		}
+	}
+	return nil
+}
+
+func TestFrame(t *testing.T) {
+	rep := report.New(false)
+	rep.RunID = "abc123"
+	done := rep.Node("node1")
+	done.StartPhase(report.PhaseCordon).Succeed()
+	done.Finish(nil)
+	draining := rep.Node("node2")
+	draining.StartPhase(report.PhaseCordon).Succeed()
+	draining.StartPhase(report.PhaseDrain)
+
+	d := &Dashboard{Report: rep, Nodes: []string{"node1", "node2", "node3"}, podsLeft: map[string]int{"node2": 4}}
+	d.Write([]byte("INFO Draining node node=node2\n"))
+	out := d.Frame(time.Now().Add(90 * time.Second))
+
+	tests := []struct {
+		node string
+		want []string
+	}{
+		{node: "node1", want: []string{"node1", "succeeded", "-"}},
+		{node: "node2", want: []string{"node2", "running", "drain", "1m30s", "4"}},
+		{node: "node3", want: []string{"node3", "pending", "-", "-", "-"}},
+	}
+	for _, tt := range tests {
+		got := row(out, tt.node)
+		if len(got) < len(tt.want) || strings.Join(got[:len(tt.want)], " ") != strings.Join(tt.want, " ") {
+			t.Errorf("row %s = %v, want prefix %v\n%s", tt.node, got, tt.want, out)
+		}
+	}
+	for _, want := range []string{"run abc123", "1/3 nodes done, 0 failed", "Draining node node=node2"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("frame missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	rep := report.New(false)
+	rep.Node("node1").Finish(nil)
+	failed := rep.Node("node2")
+	failed.AddEvictedPods([]string{"default/web", "default/db"}, 0)
+	failed.Finish(errors.New("drain timed out"))
+
+	var out, logs bytes.Buffer
+	var asked []string
+	d := &Dashboard{
+		Out:    &out,
+		Log:    &logs,
+		Report: rep,
+		Nodes:  []string{"node1", "node2", "node3"},
+		PodsLeft: func(_ context.Context, node string) (int, error) {
+			asked = append(asked, node)
+			return 0, nil
+		},
+		Interval: time.Hour,
+	}
+	d.Start()
+	d.Write([]byte("passed on\n"))
+	if logs.String() != "passed on\n" {
+		t.Errorf("logs = %q, want the line written right away", logs.String())
+	}
+	if !d.Stop() {
+		t.Error("Stop() = false, want true")
+	}
+
+	if len(asked) != 0 {
+		t.Errorf("pods left counted for nodes not being drained: %v", asked)
+	}
+	got := out.String()
+	if !strings.Contains(got, "\x1b[J") {
+		t.Errorf("live view was not erased:\n%q", got)
+	}
+	summary := got[strings.LastIndex(got, "\x1b[J"):]
+	if f := row(summary, "node2"); len(f) < 4 || f[1] != "failed" || f[3] != "2" || !strings.Contains(summary, "drain timed out") {
+		t.Errorf("unexpected summary row for node2 %v:\n%s", f, summary)
+	}
+	if !strings.Contains(summary, "1 succeeded, 1 failed, 1 not restarted") {
+		t.Errorf("summary missing totals:\n%s", summary)
+	}
+	if d.Stop() {
+		t.Error("second Stop() = true, want false")
+	}
+}
+
+func TestRecentLogs(t *testing.T) {
+	d := &Dashboard{}
+	for i := 1; i <= logLines+3; i++ {
+		fmt.Fprintf(d, "line %d\n", i)
+	}
+	d.Write([]byte("partial"))
+
+	got := d.recentLogs()
+	if len(got) != logLines || got[0] != "line 4" || got[logLines-1] != fmt.Sprintf("line %d", logLines+3) {
+		t.Errorf("recentLogs() = %q, want the last %d complete lines", got, logLines)
+	}
+	d.Write([]byte(" line\n"))
+	if got := d.recentLogs(); got[logLines-1] != "partial line" {
+		t.Errorf("recentLogs() = %q, want the completed line last", got)
+	}
+}
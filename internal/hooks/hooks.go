@@ -0,0 +1,192 @@
+// Package hooks runs local executables at points of a node's restart so
+// that operators can integrate kubectl-reboot with their own tooling.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Event names the point of a node's restart at which hooks run.
+type Event string
+
+const (
+	BeforeCordon   Event = "before-cordon"
+	AfterDrain     Event = "after-drain"
+	AfterReboot    Event = "after-reboot"
+	BeforeUncordon Event = "before-uncordon"
+	OnFailure      Event = "on-failure"
+)
+
+// Events lists the supported events in the order they occur.
+var Events = []Event{BeforeCordon, AfterDrain, AfterReboot, BeforeUncordon, OnFailure}
+
+// DefaultTimeout is the default time a hook may run, in seconds.
+const DefaultTimeout = 60
+
+// maxOutput bounds the output kept from the hooks of one event.
+const maxOutput = 16 << 10
+
+// Hook is an executable registered for an event with --hook EVENT=PATH.
+type Hook struct {
+	Event Event
+	Path  string
+}
+
+// Parse parses a --hook value.
+func Parse(s string) (Hook, error) {
+	event, path, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return Hook{}, fmt.Errorf("--hook %q: want EVENT=PATH", s)
+	}
+	h := Hook{Event: Event(event), Path: path}
+	if !h.Event.valid() {
+		return Hook{}, fmt.Errorf("--hook %q: unknown event %q (want one of %s)", s, event, eventList())
+	}
+	return h, nil
+}
+
+// IsBefore reports whether a failing hook for e vetoes the step that follows.
+func (e Event) IsBefore() bool {
+	return strings.HasPrefix(string(e), "before-")
+}
+
+func (e Event) valid() bool {
+	for _, v := range Events {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func eventList() string {
+	names := make([]string, len(Events))
+	for i, e := range Events {
+		names[i] = string(e)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Payload is written as JSON to each hook's stdin.
+type Payload struct {
+	Event        Event     `json:"event"`
+	RunID        string    `json:"runID"`
+	Node         string    `json:"node"`
+	Phase        string    `json:"phase,omitempty"`
+	Time         time.Time `json:"time"`
+	Operator     string    `json:"operator,omitempty"`
+	DryRun       bool      `json:"dryRun"`
+	BootIDBefore string    `json:"bootIDBefore,omitempty"`
+	BootIDAfter  string    `json:"bootIDAfter,omitempty"`
+	PodsEvicted  []string  `json:"podsEvicted,omitempty"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// env returns the KUBECTL_REBOOT_* variables describing p.
+func (p Payload) env() []string {
+	env := []string{
+		"KUBECTL_REBOOT_EVENT=" + string(p.Event),
+		"KUBECTL_REBOOT_RUN_ID=" + p.RunID,
+		"KUBECTL_REBOOT_NODE=" + p.Node,
+		"KUBECTL_REBOOT_PHASE=" + p.Phase,
+		"KUBECTL_REBOOT_DRY_RUN=" + strconv.FormatBool(p.DryRun),
+	}
+	if p.Error != "" {
+		env = append(env, "KUBECTL_REBOOT_ERROR="+p.Error)
+	}
+	return env
+}
+
+// Runner runs the registered hooks.
+type Runner struct {
+	Hooks   []Hook
+	Timeout time.Duration
+	DryRun  bool
+	Logger  *log.Logger
+}
+
+// Has reports whether any hook is registered for event.
+func (r *Runner) Has(event Event) bool {
+	if r == nil {
+		return false
+	}
+	for _, h := range r.Hooks {
+		if h.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs the hooks registered for p.Event in order, stopping at the first
+// one that fails, and returns their combined output.
+func (r *Runner) Run(ctx context.Context, p Payload) (string, error) {
+	stdin, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	for _, h := range r.Hooks {
+		if h.Event != p.Event {
+			continue
+		}
+		if r.DryRun {
+			r.info("Hook skipped (dry-run)", "event", h.Event, "hook", h.Path, "node", p.Node)
+			continue
+		}
+		if err := r.run(ctx, h, p, stdin, &out); err != nil {
+			return tail(out.String()), err
+		}
+	}
+	return tail(out.String()), nil
+}
+
+func (r *Runner) run(ctx context.Context, h Hook, p Payload, stdin []byte, out *bytes.Buffer) error {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	r.info("Running hook", "event", h.Event, "hook", h.Path, "node", p.Node)
+
+	cmd := exec.CommandContext(ctx, h.Path)
+	cmd.Env = append(os.Environ(), p.env()...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = out
+	cmd.Stderr = out
+	// Do not wait on pipes held open by processes the hook left behind.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("hook %s timed out after %s", h.Path, r.Timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("hook %s: %w", h.Path, err)
+	}
+	r.info("Hook completed", "event", h.Event, "hook", h.Path, "node", p.Node, "duration", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func tail(s string) string {
+	if len(s) > maxOutput {
+		return s[len(s)-maxOutput:]
+	}
+	return s
+}
+
+func (r *Runner) info(msg string, keyvals ...any) {
+	if r.Logger != nil {
+		r.Logger.Info(msg, keyvals...)
+	}
+}
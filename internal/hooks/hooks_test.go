@@ -0,0 +1,122 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script to dir and returns its path.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    Hook
+		wantErr bool
+	}{
+		{spec: "before-cordon=/usr/local/bin/silence", want: Hook{Event: BeforeCordon, Path: "/usr/local/bin/silence"}},
+		{spec: "on-failure=./page.sh", want: Hook{Event: OnFailure, Path: "./page.sh"}},
+		{spec: "after-cordon=/bin/true", wantErr: true},
+		{spec: "before-uncordon=", wantErr: true},
+		{spec: "/bin/true", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunPassesEnvAndPayload(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "env")
+	stdinFile := filepath.Join(dir, "stdin")
+	hook := writeScript(t, dir, "hook.sh", `echo "$KUBECTL_REBOOT_EVENT $KUBECTL_REBOOT_NODE $KUBECTL_REBOOT_PHASE $KUBECTL_REBOOT_RUN_ID" > `+envFile+`
+cat > `+stdinFile+`
+echo done
+`)
+	other := writeScript(t, dir, "other.sh", "echo wrong event\nexit 1\n")
+	r := &Runner{Hooks: []Hook{{Event: AfterDrain, Path: hook}, {Event: BeforeCordon, Path: other}}, Timeout: 5 * time.Second}
+
+	out, err := r.Run(context.Background(), Payload{Event: AfterDrain, RunID: "abc123", Node: "node1", Phase: "drain", PodsEvicted: []string{"default/web-1"}})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if strings.TrimSpace(out) != "done" {
+		t.Errorf("output = %q, want %q", out, "done")
+	}
+	env, _ := os.ReadFile(envFile)
+	if got := strings.TrimSpace(string(env)); got != "after-drain node1 drain abc123" {
+		t.Errorf("hook environment = %q", got)
+	}
+	data, _ := os.ReadFile(stdinFile)
+	var p Payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatalf("stdin is not a JSON payload: %v\n%s", err, data)
+	}
+	if p.Event != AfterDrain || p.Node != "node1" || len(p.PodsEvicted) != 1 {
+		t.Errorf("unexpected payload %+v", p)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "second-ran")
+	tests := []struct {
+		name  string
+		first string
+	}{
+		{name: "non-zero exit", first: "echo vetoed\nexit 3\n"},
+		{name: "timeout", first: "sleep 5\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := writeScript(t, dir, "first.sh", tt.first)
+			second := writeScript(t, dir, "second.sh", "touch "+marker+"\n")
+			r := &Runner{Hooks: []Hook{{Event: BeforeUncordon, Path: first}, {Event: BeforeUncordon, Path: second}}, Timeout: 200 * time.Millisecond}
+
+			if _, err := r.Run(context.Background(), Payload{Event: BeforeUncordon, Node: "node1"}); err == nil {
+				t.Fatal("Run() should fail")
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Error("hooks after a failed one should not run")
+			}
+		})
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	dir := t.TempDir()
+	hook := writeScript(t, dir, "hook.sh", "exit 1\n")
+	r := &Runner{Hooks: []Hook{{Event: BeforeCordon, Path: hook}}, DryRun: true}
+	if _, err := r.Run(context.Background(), Payload{Event: BeforeCordon, Node: "node1"}); err != nil {
+		t.Errorf("Run() in dry-run mode error = %v", err)
+	}
+}
+
+func TestEventIsBefore(t *testing.T) {
+	for _, e := range Events {
+		want := e == BeforeCordon || e == BeforeUncordon
+		if e.IsBefore() != want {
+			t.Errorf("%s.IsBefore() = %v, want %v", e, e.IsBefore(), want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CapacityCheck is the result of checking whether the rest of the cluster
+// can absorb the pods evicted from a node.
+type CapacityCheck struct {
+	// Pods is the number of pods that would be evicted.
+	Pods int
+	// Candidates is the number of nodes considered for rescheduling.
+	Candidates int
+	// Unplaceable lists evicted pods, as namespace/name, that fit on no
+	// candidate node.
+	Unplaceable []string
+}
+
+// OK reports whether every evicted pod found room on a candidate node.
+func (c *CapacityCheck) OK() bool {
+	return len(c.Unplaceable) == 0
+}
+
+func (c *CapacityCheck) String() string {
+	if c.OK() {
+		return fmt.Sprintf("%d pod(s) fit on %d node(s)", c.Pods, c.Candidates)
+	}
+	return fmt.Sprintf("%d of %d pod(s) do not fit on the %d remaining schedulable node(s): %s",
+		len(c.Unplaceable), c.Pods, c.Candidates, strings.Join(c.Unplaceable, ", "))
+}
+
+// activePodsSelector selects the pods that are neither Succeeded nor Failed.
+const activePodsSelector = "status.phase!=Failed,status.phase!=Succeeded"
+
+// nodeRoom is what is left of a candidate node's allocatable resources.
+type nodeRoom struct {
+	node     *corev1.Node
+	milliCPU int64
+	memory   int64
+	pods     int64
+}
+
+// CheckCapacity simulates placing the evictable pods among pods, as listed
+// by ListNodePods for node, onto the other Ready, schedulable nodes that are
+// not in exclude. The pods of the other nodes are listed without the
+// finished ones. Placement is first-fit by CPU and memory requests and pod
+// count, honouring NoSchedule/NoExecute taints and nodeSelector; affinity
+// and topology spread are not considered, so the result is an estimate.
+func (c *Client) CheckCapacity(ctx context.Context, node string, pods []corev1.Pod, exclude []string) (*CapacityCheck, error) {
+	nodes, err := c.CS.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// Only pods still holding resources count against the other nodes; the
+	// API server filters out the finished ones, which can be most of the
+	// pods on clusters running many Jobs.
+	all, err := c.CS.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: activePodsSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	skip := map[string]bool{node: true}
+	for _, n := range exclude {
+		skip[n] = true
+	}
+	rooms := map[string]*nodeRoom{}
+	var order []string
+	for i := range nodes.Items {
+		n := &nodes.Items[i]
+		if skip[n.Name] || n.Spec.Unschedulable || !IsNodeReady(n) {
+			continue
+		}
+		alloc := n.Status.Allocatable
+		rooms[n.Name] = &nodeRoom{
+			node:     n,
+			milliCPU: alloc.Cpu().MilliValue(),
+			memory:   alloc.Memory().Value(),
+			pods:     alloc.Pods().Value(),
+		}
+		order = append(order, n.Name)
+	}
+	for i := range all.Items {
+		p := &all.Items[i]
+		room, ok := rooms[p.Spec.NodeName]
+		if !ok || isTerminal(p) {
+			continue
+		}
+		cpu, mem := podRequests(p)
+		room.milliCPU -= cpu
+		room.memory -= mem
+		room.pods--
+	}
+
+	var evicted []*corev1.Pod
+	for i := range pods {
+		if !c.shouldSkipPod(&pods[i]) && !isTerminal(&pods[i]) {
+			evicted = append(evicted, &pods[i])
+		}
+	}
+	// Place the largest pods first so that small ones fill the gaps.
+	sort.SliceStable(evicted, func(i, j int) bool {
+		ci, mi := podRequests(evicted[i])
+		cj, mj := podRequests(evicted[j])
+		if ci != cj {
+			return ci > cj
+		}
+		return mi > mj
+	})
+
+	check := &CapacityCheck{Pods: len(evicted), Candidates: len(order)}
+	for _, p := range evicted {
+		cpu, mem := podRequests(p)
+		placed := false
+		for _, name := range order {
+			room := rooms[name]
+			if room.milliCPU < cpu || room.memory < mem || room.pods < 1 || !schedulableOn(p, room.node) {
+				continue
+			}
+			room.milliCPU -= cpu
+			room.memory -= mem
+			room.pods--
+			placed = true
+			break
+		}
+		if !placed {
+			check.Unplaceable = append(check.Unplaceable, p.Namespace+"/"+p.Name)
+		}
+	}
+	return check, nil
+}
+
+// podRequests returns the CPU (millicores) and memory (bytes) the scheduler
+// reserves for p: the larger of the summed containers and any single init
+// container, plus the pod overhead.
+func podRequests(p *corev1.Pod) (int64, int64) {
+	var cpu, mem int64
+	for _, c := range p.Spec.Containers {
+		cpu += c.Resources.Requests.Cpu().MilliValue()
+		mem += c.Resources.Requests.Memory().Value()
+	}
+	for _, c := range p.Spec.InitContainers {
+		if v := c.Resources.Requests.Cpu().MilliValue(); v > cpu {
+			cpu = v
+		}
+		if v := c.Resources.Requests.Memory().Value(); v > mem {
+			mem = v
+		}
+	}
+	cpu += p.Spec.Overhead.Cpu().MilliValue()
+	mem += p.Spec.Overhead.Memory().Value()
+	return cpu, mem
+}
+
+// schedulableOn roughly reports whether the scheduler could put p on n.
+func schedulableOn(p *corev1.Pod, n *corev1.Node) bool {
+	for k, v := range p.Spec.NodeSelector {
+		if n.Labels[k] != v {
+			return false
+		}
+	}
+	for i := range n.Spec.Taints {
+		t := &n.Spec.Taints[i]
+		if t.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !tolerates(p.Spec.Tolerations, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func tolerates(tolerations []corev1.Toleration, t *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTerminal(p *corev1.Pod) bool {
+	return p.Status.Phase == corev1.PodSucceeded || p.Status.Phase == corev1.PodFailed
+}
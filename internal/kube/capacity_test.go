@@ -0,0 +1,165 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func capacityNode(name, cpu, mem string, mutate func(*corev1.Node)) *corev1.Node {
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(mem),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	if mutate != nil {
+		mutate(n)
+	}
+	return n
+}
+
+func capacityPod(name, node, cpu, mem string, mutate func(*corev1.Pod)) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse(cpu),
+					corev1.ResourceMemory: resource.MustParse(mem),
+				}},
+			}},
+		},
+	}
+	if mutate != nil {
+		mutate(p)
+	}
+	return p
+}
+
+func TestCheckCapacity(t *testing.T) {
+	tests := []struct {
+		name            string
+		objs            []runtime.Object
+		exclude         []string
+		wantUnplaceable []string
+	}{
+		{
+			name: "fits on remaining node",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("node2", "4", "8Gi", nil),
+				capacityPod("web-1", "node1", "1", "1Gi", nil),
+				capacityPod("web-2", "node1", "2", "2Gi", nil),
+				capacityPod("db-0", "node2", "1", "4Gi", nil),
+			},
+		},
+		{
+			name: "not enough cpu left",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("node2", "4", "8Gi", nil),
+				capacityPod("web-1", "node1", "3", "1Gi", nil),
+				capacityPod("db-0", "node2", "2", "1Gi", nil),
+			},
+			wantUnplaceable: []string{"default/web-1"},
+		},
+		{
+			name: "cordoned and excluded nodes do not count",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("node2", "4", "8Gi", func(n *corev1.Node) { n.Spec.Unschedulable = true }),
+				capacityNode("node3", "4", "8Gi", nil),
+				capacityPod("web-1", "node1", "1", "1Gi", nil),
+			},
+			exclude:         []string{"node3"},
+			wantUnplaceable: []string{"default/web-1"},
+		},
+		{
+			name: "taint not tolerated",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("gpu-1", "4", "8Gi", func(n *corev1.Node) {
+					n.Spec.Taints = []corev1.Taint{{Key: "nvidia.com/gpu", Effect: corev1.TaintEffectNoSchedule}}
+				}),
+				capacityPod("web-1", "node1", "1", "1Gi", nil),
+				capacityPod("train-1", "node1", "1", "1Gi", func(p *corev1.Pod) {
+					p.Spec.Tolerations = []corev1.Toleration{{Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists}}
+				}),
+			},
+			wantUnplaceable: []string{"default/web-1"},
+		},
+		{
+			name: "node selector must match",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("node2", "4", "8Gi", func(n *corev1.Node) { n.Labels["zone"] = "a" }),
+				capacityPod("web-1", "node1", "1", "1Gi", func(p *corev1.Pod) { p.Spec.NodeSelector = map[string]string{"zone": "b"} }),
+			},
+			wantUnplaceable: []string{"default/web-1"},
+		},
+		{
+			name: "daemonset pods are not moved",
+			objs: []runtime.Object{
+				capacityNode("node1", "4", "8Gi", nil),
+				capacityNode("node2", "1", "1Gi", nil),
+				capacityPod("fluentd-abc", "node1", "2", "2Gi", func(p *corev1.Pod) {
+					p.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd"}}
+				}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{CS: fake.NewSimpleClientset(tt.objs...)}
+			ctx := context.Background()
+			pods, err := client.ListNodePods(ctx, "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			check, err := client.CheckCapacity(ctx, "node1", pods, tt.exclude)
+			if err != nil {
+				t.Fatalf("CheckCapacity() error = %v", err)
+			}
+			if len(check.Unplaceable) != len(tt.wantUnplaceable) {
+				t.Fatalf("Unplaceable = %v, want %v (%s)", check.Unplaceable, tt.wantUnplaceable, check)
+			}
+			for i := range tt.wantUnplaceable {
+				if check.Unplaceable[i] != tt.wantUnplaceable[i] {
+					t.Errorf("Unplaceable = %v, want %v", check.Unplaceable, tt.wantUnplaceable)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckCapacityListsOnlyActivePods(t *testing.T) {
+	cs := fake.NewSimpleClientset(capacityNode("node1", "4", "8Gi", nil), capacityNode("node2", "4", "8Gi", nil))
+	var selectors []string
+	cs.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selectors = append(selectors, action.(k8stesting.ListAction).GetListRestrictions().Fields.String())
+		return false, nil, nil
+	})
+	client := &Client{CS: cs}
+
+	if _, err := client.CheckCapacity(context.Background(), "node1", nil, nil); err != nil {
+		t.Fatalf("CheckCapacity() error = %v", err)
+	}
+	if len(selectors) != 1 || selectors[0] != activePodsSelector {
+		t.Errorf("pod lists with field selectors %q, want one with %q", selectors, activePodsSelector)
+	}
+}
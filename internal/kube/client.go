@@ -2,9 +2,8 @@ package kube
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -12,51 +11,53 @@ import (
 	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+// Annotations kubectl-reboot writes on a node after restarting it.
+const (
+	AnnotationLastRebootTime = "kubectl-reboot.io/last-reboot-time"
+	AnnotationLastRebootBy   = "kubectl-reboot.io/last-reboot-by"
+	AnnotationPreviousBootID = "kubectl-reboot.io/previous-boot-id"
+	AnnotationBootID         = "kubectl-reboot.io/boot-id"
+	AnnotationToolVersion    = "kubectl-reboot.io/version"
+	// AnnotationCordonedBy is set while kubectl-reboot holds a node
+	// cordoned and names the operator that cordoned it.
+	AnnotationCordonedBy = "kubectl-reboot.io/cordoned-by"
+	EventSourceComponent = "kubectl-reboot"
+
+	// TaintFailed marks a node whose restart failed; its value names the
+	// phase that failed.
+	TaintFailed = "kubectl-reboot.io/failed"
 )
 
 type Client struct {
-	CS     *kubernetes.Clientset
+	CS kubernetes.Interface
+	// Dyn serves the NodeRebootPlan custom resources of controller mode.
+	Dyn    dynamic.Interface
 	logger *log.Logger
 }
 
-func New(kubeconfigPath, contextName string, logger *log.Logger) (*Client, error) {
-	var restCfg *rest.Config
-	var err error
-	if kubeconfigPath != "" {
-		abs := kubeconfigPath
-		if !filepath.IsAbs(abs) {
-			if abs, err = filepath.Abs(abs); err != nil {
-				return nil, err
-			}
-		}
-		restCfg, err = clientcmd.BuildConfigFromFlags("", abs)
-	} else {
-		kubeconfig := clientcmd.RecommendedHomeFile
-		if _, statErr := os.Stat(kubeconfig); statErr == nil {
-			restCfg, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
-		} else {
-			restCfg, err = rest.InClusterConfig()
-		}
-	}
+// New builds a client from the standard kubectl connection flags, so
+// --kubeconfig, --context, --as and friends resolve exactly as in kubectl
+// (including the in-cluster fallback when no kubeconfig is found).
+func New(getter genericclioptions.RESTClientGetter, logger *log.Logger) (*Client, error) {
+	restCfg, err := getter.ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
-	if contextName != "" && kubeconfigPath != "" {
-		loadingRules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfigPath}
-		cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: contextName})
-		restCfg, err = cc.ClientConfig()
-		if err != nil {
-			return nil, err
-		}
-	}
 	cs, err := kubernetes.NewForConfig(restCfg)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{CS: cs, logger: logger}, nil
+	dyn, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{CS: cs, Dyn: dyn, logger: logger}, nil
 }
 
 func (c *Client) ListNodeNames(excludeControlPlane bool) ([]string, error) {
@@ -76,15 +77,140 @@ func (c *Client) ListNodeNames(excludeControlPlane bool) ([]string, error) {
 	return names, nil
 }
 
-func (c *Client) Cordon(ctx context.Context, nodeName string) error {
-	patch := []byte(`{"spec":{"unschedulable":true}}`)
-	_, err := c.CS.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
-	return err
+// ListNodesMatching returns the names of the nodes matching the label
+// selector.
+func (c *Client) ListNodesMatching(ctx context.Context, selector string) ([]string, error) {
+	list, err := c.CS.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(list.Items))
+	for _, n := range list.Items {
+		names = append(names, n.Name)
+	}
+	return names, nil
 }
 
+// Cordon marks the node unschedulable. A non-empty by is recorded in the
+// cordoned-by annotation, so the cordon can later be told apart from one
+// made by someone else.
+func (c *Client) Cordon(ctx context.Context, nodeName, by string) error {
+	patch := map[string]any{"spec": map[string]any{"unschedulable": true}}
+	if by != "" {
+		patch["metadata"] = map[string]any{"annotations": map[string]any{AnnotationCordonedBy: by}}
+	}
+	return c.patchNode(ctx, nodeName, patch)
+}
+
+// Uncordon marks the node schedulable and drops the cordoned-by annotation.
 func (c *Client) Uncordon(ctx context.Context, nodeName string) error {
-	patch := []byte(`{"spec":{"unschedulable":false}}`)
-	_, err := c.CS.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	return c.patchNode(ctx, nodeName, map[string]any{
+		"metadata": map[string]any{"annotations": map[string]any{AnnotationCordonedBy: nil}},
+		"spec":     map[string]any{"unschedulable": false},
+	})
+}
+
+func (c *Client) patchNode(ctx context.Context, nodeName string, patch map[string]any) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.CS.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+// TaintNode adds taint to the node, replacing any taint with the same key
+// and effect.
+func (c *Client) TaintNode(ctx context.Context, nodeName string, taint corev1.Taint) error {
+	return c.updateTaints(ctx, nodeName, func(taints []corev1.Taint) []corev1.Taint {
+		return append(withoutTaint(taints, taint.Key), taint)
+	})
+}
+
+// RemoveTaint removes every taint with key from the node. It reports whether
+// the node carried one.
+func (c *Client) RemoveTaint(ctx context.Context, nodeName, key string) (bool, error) {
+	removed := false
+	err := c.updateTaints(ctx, nodeName, func(taints []corev1.Taint) []corev1.Taint {
+		kept := withoutTaint(taints, key)
+		removed = len(kept) != len(taints)
+		return kept
+	})
+	return removed, err
+}
+
+// updateTaints rewrites the node's taints with edit, retrying on conflicts.
+// Taints are a plain list in the Node schema, so a patch would replace them
+// wholesale; a read-modify-write keeps other taints intact.
+func (c *Client) updateTaints(ctx context.Context, nodeName string, edit func([]corev1.Taint) []corev1.Taint) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		nd, err := c.GetNode(ctx, nodeName)
+		if err != nil {
+			return err
+		}
+		taints := edit(append([]corev1.Taint(nil), nd.Spec.Taints...))
+		if len(taints) == len(nd.Spec.Taints) && taintsEqual(taints, nd.Spec.Taints) {
+			return nil
+		}
+		nd.Spec.Taints = taints
+		_, err = c.CS.CoreV1().Nodes().Update(ctx, nd, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+func withoutTaint(taints []corev1.Taint, key string) []corev1.Taint {
+	var out []corev1.Taint
+	for _, t := range taints {
+		if t.Key != key {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func taintsEqual(a, b []corev1.Taint) bool {
+	for i := range a {
+		if !a[i].MatchTaint(&b[i]) || a[i].Value != b[i].Value {
+			return false
+		}
+	}
+	return true
+}
+
+// AnnotateNode merges annotations into the node's metadata.
+func (c *Client) AnnotateNode(ctx context.Context, nodeName string, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]any{"metadata": map[string]any{"annotations": annotations}})
+	if err != nil {
+		return err
+	}
+	_, err = c.CS.CoreV1().Nodes().Patch(ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
+
+// RecordNodeEvent creates a core/v1 Event about the node, so it shows up in
+// `kubectl describe node` the same way kubelet events do.
+func (c *Client) RecordNodeEvent(ctx context.Context, node *corev1.Node, eventType, reason, message string) error {
+	now := metav1.NewTime(time.Now())
+	ev := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", node.Name, now.UnixNano()),
+			Namespace: metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: EventSourceComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := c.CS.CoreV1().Events(metav1.NamespaceDefault).Create(ctx, ev, metav1.CreateOptions{})
 	return err
 }
 
@@ -99,7 +225,11 @@ func (c *Client) WaitForCondition(ctx context.Context, node string, pred func(*c
 		if err == nil && pred(n) {
 			return true
 		}
-		time.Sleep(interval)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
 	}
 	return false
 }
@@ -113,27 +243,58 @@ func (c *Client) WaitForBootIDChange(ctx context.Context, node, before string, t
 				return true
 			}
 		}
-		time.Sleep(interval)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(interval):
+		}
 	}
 	return false
 }
 
-func (c *Client) EvictPods(ctx context.Context, node string, pollInterval time.Duration, timeout time.Duration, dryRun bool) error {
+// EvictionResult describes what EvictPods did on a node.
+type EvictionResult struct {
+	// Evicted lists the pods an eviction was sent for (or, in dry-run,
+	// would have been) as namespace/name.
+	Evicted []string
+	// Retries counts evictions re-sent and accepted for pods that were
+	// still running, typically because a PodDisruptionBudget refused the
+	// first attempt.
+	Retries int
+}
+
+// ListNodePods lists the pods scheduled to node. The same list feeds the
+// capacity check and EvictPods, so both reason about the same pods.
+func (c *Client) ListNodePods(ctx context.Context, node string) ([]corev1.Pod, error) {
 	pods, err := c.CS.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: fmt.Sprintf("spec.nodeName=%s", node)})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	items := pods.Items[:0]
+	for _, p := range pods.Items {
+		if p.Spec.NodeName == node {
+			items = append(items, p)
+		}
 	}
+	return items, nil
+}
 
+// EvictPods evicts the evictable pods among pods, as listed by ListNodePods,
+// and waits for them to leave node, re-sending evictions that did not take
+// until timeout.
+func (c *Client) EvictPods(ctx context.Context, node string, pods []corev1.Pod, pollInterval time.Duration, timeout time.Duration, dryRun bool) (*EvictionResult, error) {
 	// Evict eligible pods
-	if err := c.evictEligiblePods(ctx, pods.Items, dryRun); err != nil {
-		return err
+	res := &EvictionResult{Evicted: c.evictEligiblePods(ctx, node, pods, dryRun)}
+	if dryRun {
+		return res, nil
 	}
 
 	// Wait for eviction completion
-	return c.waitForEvictionCompletion(ctx, node, pollInterval, timeout)
+	return res, c.waitForEvictionCompletion(ctx, node, pollInterval, timeout, res)
 }
 
-func (c *Client) evictEligiblePods(ctx context.Context, pods []corev1.Pod, dryRun bool) error {
+func (c *Client) evictEligiblePods(ctx context.Context, node string, pods []corev1.Pod, dryRun bool) []string {
+	var evicted []string
 	for _, p := range pods {
 		if c.shouldSkipPod(&p) {
 			continue
@@ -141,22 +302,24 @@ func (c *Client) evictEligiblePods(ctx context.Context, pods []corev1.Pod, dryRu
 
 		if dryRun {
 			if c.logger != nil {
-				c.logger.Info("🧪 DRY-RUN: Would evict pod", "namespace", p.Namespace, "pod", p.Name)
+				c.logger.Info("Pod eviction skipped (dry-run)", "node", node, "phase", "drain", "namespace", p.Namespace, "pod", p.Name)
 			}
+			evicted = append(evicted, p.Namespace+"/"+p.Name)
 			continue
 		}
 
 		if err := c.evictSinglePod(ctx, &p); err != nil {
 			if c.logger != nil {
-				c.logger.Warn("⚠️  Failed to evict pod", "namespace", p.Namespace, "pod", p.Name, "error", err)
+				c.logger.Warn("Pod eviction failed", "node", node, "phase", "drain", "namespace", p.Namespace, "pod", p.Name, "error", err)
 			}
 		} else {
+			evicted = append(evicted, p.Namespace+"/"+p.Name)
 			if c.logger != nil {
-				c.logger.Info("🏃 Eviction sent for pod", "namespace", p.Namespace, "pod", p.Name)
+				c.logger.Info("Pod eviction sent", "node", node, "phase", "drain", "namespace", p.Namespace, "pod", p.Name)
 			}
 		}
 	}
-	return nil
+	return evicted
 }
 
 func (c *Client) shouldSkipPod(p *corev1.Pod) bool {
@@ -171,9 +334,9 @@ func (c *Client) evictSinglePod(ctx context.Context, p *corev1.Pod) error {
 	return c.CS.CoreV1().Pods(p.Namespace).EvictV1(ctx, eviction)
 }
 
-func (c *Client) waitForEvictionCompletion(ctx context.Context, node string, pollInterval time.Duration, timeout time.Duration) error {
+func (c *Client) waitForEvictionCompletion(ctx context.Context, node string, pollInterval time.Duration, timeout time.Duration, res *EvictionResult) error {
 	deadline := time.Now().Add(timeout)
-	for time.Now().Before(deadline) {
+	for first := true; time.Now().Before(deadline); first = false {
 		left, err := c.CS.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: fmt.Sprintf("spec.nodeName=%s", node)})
 		if err != nil {
 			return err
@@ -183,11 +346,52 @@ func (c *Client) waitForEvictionCompletion(ctx context.Context, node string, pol
 		if evictable == 0 {
 			return nil
 		}
-		time.Sleep(pollInterval)
+		// The evictions were just sent before the first poll.
+		if !first {
+			c.retryEvictions(ctx, node, left.Items, res)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
 	}
 	return fmt.Errorf("timeout waiting for pods eviction on %s", node)
 }
 
+// retryEvictions re-sends evictions for pods that are still running.
+func (c *Client) retryEvictions(ctx context.Context, node string, pods []corev1.Pod, res *EvictionResult) {
+	for _, p := range pods {
+		if c.shouldSkipPod(&p) {
+			continue
+		}
+		if err := c.evictSinglePod(ctx, &p); err != nil {
+			if c.logger != nil {
+				c.logger.Debug("Pod eviction retry failed", "node", node, "phase", "drain", "namespace", p.Namespace, "pod", p.Name, "error", err)
+			}
+			continue
+		}
+		res.Retries++
+		key := p.Namespace + "/" + p.Name
+		if !containsString(res.Evicted, key) {
+			res.Evicted = append(res.Evicted, key)
+		}
+		if c.logger != nil {
+			c.logger.Info("Pod eviction sent", "node", node, "phase", "drain", "namespace", p.Namespace, "pod", p.Name, "retry", true)
+		}
+	}
+}
+
+// CountEvictablePods returns how many of the pods on node a drain would
+// evict.
+func (c *Client) CountEvictablePods(ctx context.Context, node string) (int, error) {
+	pods, err := c.ListNodePods(ctx, node)
+	if err != nil {
+		return 0, err
+	}
+	return c.countEvictablePods(pods), nil
+}
+
 func (c *Client) countEvictablePods(pods []corev1.Pod) int {
 	evictable := 0
 	for _, p := range pods {
@@ -229,3 +433,12 @@ func hasCritical(p *corev1.Pod) bool {
 }
 
 func int64Ptr(i int64) *int64 { return &i }
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,17 @@
 package kube
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestIsNodeReady(t *testing.T) {
@@ -383,3 +390,224 @@ func TestCountEvictablePods(t *testing.T) {
 		t.Errorf("countEvictablePods() = %d, want %d", result, expected)
 	}
 }
+
+func TestEvictPodsDryRun(t *testing.T) {
+	pods := []runtime.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "fluentd-abc",
+				Namespace:       "logging",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "fluentd"}},
+			},
+			Spec: corev1.PodSpec{NodeName: "node1"},
+		},
+	}
+	client := &Client{CS: fake.NewSimpleClientset(pods...)}
+
+	list, err := client.ListNodePods(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.EvictPods(context.Background(), "node1", list, time.Millisecond, time.Second, true)
+	if err != nil {
+		t.Fatalf("EvictPods() error = %v", err)
+	}
+	if len(res.Evicted) != 1 || res.Evicted[0] != "default/web-1" {
+		t.Errorf("EvictPods() = %v, want [default/web-1]", res.Evicted)
+	}
+}
+
+func TestEvictPodsRetries(t *testing.T) {
+	tooMany := apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	tests := []struct {
+		name string
+		// evict answers the n-th eviction (from 1); remove deletes the pod.
+		evict       func(n int) (remove bool, err error)
+		wantErr     bool
+		wantEvicted []string
+		wantRetries int // minimum of accepted re-sends
+	}{
+		{
+			name:        "pod stays running",
+			evict:       func(int) (bool, error) { return false, nil },
+			wantErr:     true,
+			wantEvicted: []string{"default/web-1"},
+			wantRetries: 2,
+		},
+		{
+			name: "refused by disruption budget then allowed",
+			evict: func(n int) (bool, error) {
+				if n == 1 {
+					return false, tooMany
+				}
+				return true, nil
+			},
+			wantEvicted: []string{"default/web-1"},
+			wantRetries: 1,
+		},
+		{
+			name:        "refused until timeout",
+			evict:       func(int) (bool, error) { return false, tooMany },
+			wantErr:     true,
+			wantRetries: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}}
+			cs := fake.NewSimpleClientset(pod)
+			evictions, accepted := 0, 0
+			cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.GetSubresource() != "eviction" {
+					return false, nil, nil
+				}
+				evictions++
+				remove, err := tt.evict(evictions)
+				if err == nil && evictions > 1 {
+					accepted++
+				}
+				if remove {
+					_ = cs.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), "default", "web-1")
+				}
+				return true, nil, err
+			})
+			client := &Client{CS: cs}
+
+			list, err := client.ListNodePods(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := client.EvictPods(context.Background(), "node1", list, time.Millisecond, 50*time.Millisecond, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EvictPods() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "timeout") {
+				t.Errorf("EvictPods() error = %v, want a timeout", err)
+			}
+			if strings.Join(res.Evicted, ",") != strings.Join(tt.wantEvicted, ",") {
+				t.Errorf("Evicted = %v, want %v", res.Evicted, tt.wantEvicted)
+			}
+			if res.Retries < tt.wantRetries || res.Retries != accepted {
+				t.Errorf("Retries = %d after %d accepted re-sends, want at least %d", res.Retries, accepted, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestEvictPodsRelistsBeforeRetrying(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default"}, Spec: corev1.PodSpec{NodeName: "node1"}}
+	cs := fake.NewSimpleClientset(pod)
+	evictions, lists := 0, 0
+	cs.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "eviction" {
+			evictions++
+			return true, nil, nil
+		}
+		return false, nil, nil
+	})
+	// The pod terminates between the first and the second poll.
+	cs.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		lists++
+		if lists == 3 {
+			_ = cs.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("pods"), "default", "web-1")
+		}
+		return false, nil, nil
+	})
+	client := &Client{CS: cs}
+
+	list, err := client.ListNodePods(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.EvictPods(context.Background(), "node1", list, time.Millisecond, time.Second, false)
+	if err != nil {
+		t.Fatalf("EvictPods() error = %v", err)
+	}
+	if evictions != 1 || res.Retries != 0 {
+		t.Errorf("%d evictions sent, %d retries; want the terminated pod evicted once", evictions, res.Retries)
+	}
+}
+
+func TestWaitStopsOnCancel(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	client := &Client{CS: fake.NewSimpleClientset(node)}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if client.WaitForCondition(ctx, "node1", IsNodeReady, time.Minute, time.Second) {
+		t.Error("WaitForCondition() = true for a node never Ready")
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("WaitForCondition() returned %s after cancel, want at once", took)
+	}
+}
+
+func TestAnnotateNode(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{"keep": "me"}}}
+	client := &Client{CS: fake.NewSimpleClientset(node)}
+
+	err := client.AnnotateNode(context.Background(), "node1", map[string]string{AnnotationLastRebootBy: "alice@laptop"})
+	if err != nil {
+		t.Fatalf("AnnotateNode() error = %v", err)
+	}
+	got, err := client.GetNode(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations[AnnotationLastRebootBy] != "alice@laptop" || got.Annotations["keep"] != "me" {
+		t.Errorf("unexpected annotations %v", got.Annotations)
+	}
+}
+
+func TestRecordNodeEvent(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "uid-1"}}
+	client := &Client{CS: fake.NewSimpleClientset(node)}
+
+	if err := client.RecordNodeEvent(context.Background(), node, corev1.EventTypeNormal, "Cordoned", "cordoned"); err != nil {
+		t.Fatalf("RecordNodeEvent() error = %v", err)
+	}
+	events, err := client.CS.CoreV1().Events(metav1.NamespaceDefault).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("Expected 1 event, got %d", len(events.Items))
+	}
+	ev := events.Items[0]
+	if ev.InvolvedObject.Kind != "Node" || ev.InvolvedObject.Name != "node1" || ev.InvolvedObject.UID != "uid-1" || ev.Reason != "Cordoned" || ev.Source.Component != EventSourceComponent {
+		t.Errorf("unexpected event %+v", ev)
+	}
+}
+
+func TestTaintNode(t *testing.T) {
+	other := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}, Spec: corev1.NodeSpec{Taints: []corev1.Taint{other}}}
+	client := &Client{CS: fake.NewSimpleClientset(node)}
+	ctx := context.Background()
+
+	for _, phase := range []string{"drain", "wait-ready"} {
+		taint := corev1.Taint{Key: TaintFailed, Value: phase, Effect: corev1.TaintEffectNoSchedule}
+		if err := client.TaintNode(ctx, "node1", taint); err != nil {
+			t.Fatalf("TaintNode() error = %v", err)
+		}
+	}
+	got, _ := client.GetNode(ctx, "node1")
+	if len(got.Spec.Taints) != 2 || got.Spec.Taints[1].Value != "wait-ready" {
+		t.Fatalf("unexpected taints %v", got.Spec.Taints)
+	}
+
+	removed, err := client.RemoveTaint(ctx, "node1", TaintFailed)
+	if err != nil || !removed {
+		t.Fatalf("RemoveTaint() = %v, %v", removed, err)
+	}
+	got, _ = client.GetNode(ctx, "node1")
+	if len(got.Spec.Taints) != 1 || got.Spec.Taints[0].Key != "dedicated" {
+		t.Errorf("unexpected taints after removal %v", got.Spec.Taints)
+	}
+	if removed, _ := client.RemoveTaint(ctx, "node1", TaintFailed); removed {
+		t.Error("RemoveTaint() reported a removal on an untainted node")
+	}
+}
@@ -0,0 +1,114 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The health checks below report whether a rebooted node is ready to take
+// workloads again and, if not, what is still missing.
+
+// DaemonSetPodsReady checks that every DaemonSet pod scheduled to node is
+// Ready.
+func (c *Client) DaemonSetPodsReady(ctx context.Context, node string) (bool, string, error) {
+	pods, err := c.ListNodePods(ctx, node)
+	if err != nil {
+		return false, "", err
+	}
+	var notReady []string
+	for i := range pods {
+		p := &pods[i]
+		if hasOwnerKind(p, "DaemonSet") && !isTerminal(p) && !isPodReady(p) {
+			notReady = append(notReady, p.Namespace+"/"+p.Name)
+		}
+	}
+	if len(notReady) > 0 {
+		return false, "DaemonSet pods not ready: " + strings.Join(notReady, ", "), nil
+	}
+	return true, "", nil
+}
+
+// NodeConditionIs checks that the node reports condition typ with status.
+// A condition the node does not report is not met.
+func (c *Client) NodeConditionIs(ctx context.Context, node, typ string, status corev1.ConditionStatus) (bool, string, error) {
+	n, err := c.GetNode(ctx, node)
+	if err != nil {
+		return false, "", err
+	}
+	for _, cond := range n.Status.Conditions {
+		if string(cond.Type) != typ {
+			continue
+		}
+		if cond.Status == status {
+			return true, "", nil
+		}
+		return false, fmt.Sprintf("condition %s is %s, want %s", typ, cond.Status, status), nil
+	}
+	return false, fmt.Sprintf("condition %s not reported", typ), nil
+}
+
+// PodsReady checks that at least one pod matching selector runs on node and
+// that all of them are Ready.
+func (c *Client) PodsReady(ctx context.Context, node, selector string) (bool, string, error) {
+	list, err := c.CS.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+		FieldSelector: fmt.Sprintf("spec.nodeName=%s", node),
+	})
+	if err != nil {
+		return false, "", err
+	}
+	var found int
+	var notReady []string
+	for i := range list.Items {
+		p := &list.Items[i]
+		if p.Spec.NodeName != node || isTerminal(p) {
+			continue
+		}
+		found++
+		if !isPodReady(p) {
+			notReady = append(notReady, p.Namespace+"/"+p.Name)
+		}
+	}
+	if found == 0 {
+		return false, fmt.Sprintf("no pods matching %q on the node", selector), nil
+	}
+	if len(notReady) > 0 {
+		return false, "pods not ready: " + strings.Join(notReady, ", "), nil
+	}
+	return true, "", nil
+}
+
+// isPodReady reports whether the pod's Ready condition is True.
+func isPodReady(p *corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// CrashLoopingPods returns the pods on node, as namespace/name, with a
+// container waiting in CrashLoopBackOff.
+func (c *Client) CrashLoopingPods(ctx context.Context, node string) ([]string, error) {
+	pods, err := c.ListNodePods(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	var crashing []string
+	for i := range pods {
+		p := &pods[i]
+		statuses := append(append([]corev1.ContainerStatus(nil), p.Status.InitContainerStatuses...), p.Status.ContainerStatuses...)
+		for _, cs := range statuses {
+			if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+				crashing = append(crashing, p.Namespace+"/"+p.Name)
+				break
+			}
+		}
+	}
+	return crashing, nil
+}
@@ -0,0 +1,137 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func healthPod(name, node string, ready bool, labels map[string]string, owner string) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kube-system", Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+	}
+	if owner != "" {
+		p.OwnerReferences = []metav1.OwnerReference{{Kind: owner, Name: name}}
+	}
+	return p
+}
+
+func TestDaemonSetPodsReady(t *testing.T) {
+	tests := []struct {
+		name string
+		pods []runtime.Object
+		want bool
+	}{
+		{name: "all ready", pods: []runtime.Object{healthPod("cilium-a", "node1", true, nil, "DaemonSet")}, want: true},
+		{name: "one not ready", pods: []runtime.Object{healthPod("cilium-a", "node1", true, nil, "DaemonSet"), healthPod("csi-a", "node1", false, nil, "DaemonSet")}},
+		{name: "ignores other nodes and owners", pods: []runtime.Object{healthPod("csi-b", "node2", false, nil, "DaemonSet"), healthPod("web", "node1", false, nil, "ReplicaSet")}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{CS: fake.NewSimpleClientset(tt.pods...)}
+			ok, detail, err := client.DaemonSetPodsReady(context.Background(), "node1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("DaemonSetPodsReady() = %v (%s), want %v", ok, detail, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeConditionIs(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse},
+			{Type: "KernelDeadlock", Status: corev1.ConditionTrue},
+		}},
+	}
+	client := &Client{CS: fake.NewSimpleClientset(node)}
+	tests := []struct {
+		typ    string
+		status corev1.ConditionStatus
+		want   bool
+	}{
+		{typ: "MemoryPressure", status: corev1.ConditionFalse, want: true},
+		{typ: "KernelDeadlock", status: corev1.ConditionFalse},
+		{typ: "FrequentKubeletRestart", status: corev1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		ok, detail, err := client.NodeConditionIs(context.Background(), "node1", tt.typ, tt.status)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.want {
+			t.Errorf("NodeConditionIs(%s=%s) = %v (%s), want %v", tt.typ, tt.status, ok, detail, tt.want)
+		}
+	}
+}
+
+func TestPodsReady(t *testing.T) {
+	tests := []struct {
+		name string
+		pods []runtime.Object
+		want bool
+	}{
+		{name: "matching pod ready", pods: []runtime.Object{healthPod("agent-a", "node1", true, map[string]string{"app": "agent"}, "")}, want: true},
+		{name: "matching pod not ready", pods: []runtime.Object{healthPod("agent-a", "node1", false, map[string]string{"app": "agent"}, "")}},
+		{name: "no matching pod on node", pods: []runtime.Object{healthPod("agent-b", "node2", true, map[string]string{"app": "agent"}, "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{CS: fake.NewSimpleClientset(tt.pods...)}
+			ok, detail, err := client.PodsReady(context.Background(), "node1", "app=agent")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("PodsReady() = %v (%s), want %v", ok, detail, tt.want)
+			}
+		})
+	}
+}
+
+func TestCrashLoopingPods(t *testing.T) {
+	crashing := healthPod("web", "node1", false, nil, "")
+	crashing.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "sidecar", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+		{Name: "app", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	initCrashing := healthPod("db", "node1", false, nil, "")
+	initCrashing.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{Name: "migrate", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}},
+	}
+	pulling := healthPod("cache", "node1", false, nil, "")
+	pulling.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "cache", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}},
+	}
+	elsewhere := healthPod("other", "node2", false, nil, "")
+	elsewhere.Status.ContainerStatuses = crashing.Status.ContainerStatuses
+
+	client := &Client{CS: fake.NewSimpleClientset(crashing, initCrashing, pulling, elsewhere)}
+	got, err := client.CrashLoopingPods(context.Background(), "node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"kube-system/web": true, "kube-system/db": true}
+	if len(got) != len(want) {
+		t.Fatalf("CrashLoopingPods() = %v, want %v", got, want)
+	}
+	for _, p := range got {
+		if !want[p] {
+			t.Errorf("unexpected crash looping pod %s", p)
+		}
+	}
+}
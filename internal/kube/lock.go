@@ -0,0 +1,234 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// LockName is the Lease guarding against concurrent runs on a cluster.
+	LockName             = "kubectl-reboot"
+	DefaultLockNamespace = "kube-system"
+	AnnotationRunID      = "kubectl-reboot.io/run-id"
+
+	// DefaultLockDuration is how long a lock stays valid without renewal, so
+	// a crashed run blocks others for at most this long.
+	DefaultLockDuration = 60 * time.Second
+)
+
+// ErrLockLost reports that a run no longer holds the cluster lock.
+var ErrLockLost = errors.New("run lock lost")
+
+// LockHeldError reports that another run holds the cluster lock.
+type LockHeldError struct {
+	Namespace string
+	Holder    string
+	RunID     string
+	RenewedAt time.Time
+}
+
+func (e *LockHeldError) Error() string {
+	msg := fmt.Sprintf("lease %s/%s is held by %s", e.Namespace, LockName, e.Holder)
+	if e.RunID != "" {
+		msg += fmt.Sprintf(" (run %s)", e.RunID)
+	}
+	if !e.RenewedAt.IsZero() {
+		msg += fmt.Sprintf(", renewed %s ago", time.Since(e.RenewedAt).Round(time.Second))
+	}
+	return msg + "; another kubectl-reboot run is in progress (use --force-lock to take it over)"
+}
+
+// Lock is a held cluster lock. Call Release when the run ends.
+type Lock struct {
+	c         *Client
+	namespace string
+	identity  string
+	runID     string
+	duration  time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+	lostErr  error
+}
+
+// AcquireLock takes the cluster-wide Lease for identity and runID. It fails
+// with a *LockHeldError if another run holds an unexpired lease, unless
+// force is set.
+func (c *Client) AcquireLock(ctx context.Context, namespace, identity, runID string, duration time.Duration, force bool) (*Lock, error) {
+	l := &Lock{c: c, namespace: namespace, identity: identity, runID: runID, duration: duration, lost: make(chan struct{})}
+	leases := c.CS.CoordinationV1().Leases(namespace)
+
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(duration.Seconds())
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: LockName, Namespace: namespace, Annotations: map[string]string{AnnotationRunID: runID}},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		if _, err := leases.Create(ctx, lease, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				return nil, &LockHeldError{Namespace: namespace, Holder: "another run"}
+			}
+			return nil, err
+		}
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if held := heldBy(lease); held != nil && !force {
+		return nil, held
+	}
+	if lease.Annotations == nil {
+		lease.Annotations = map[string]string{}
+	}
+	lease.Annotations[AnnotationRunID] = runID
+	lease.Spec.HolderIdentity = &identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	if _, err := leases.Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) {
+			return nil, &LockHeldError{Namespace: namespace, Holder: "another run"}
+		}
+		return nil, err
+	}
+	return l, nil
+}
+
+// heldBy returns who holds lease, or nil if it is free or expired.
+func heldBy(lease *coordinationv1.Lease) *LockHeldError {
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == "" {
+		return nil
+	}
+	e := &LockHeldError{Namespace: lease.Namespace, Holder: *lease.Spec.HolderIdentity, RunID: lease.Annotations[AnnotationRunID]}
+	if lease.Spec.RenewTime != nil && lease.Spec.LeaseDurationSeconds != nil {
+		e.RenewedAt = lease.Spec.RenewTime.Time
+		expiry := e.RenewedAt.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+		if time.Now().After(expiry) {
+			return nil
+		}
+	}
+	return e
+}
+
+// Renew extends the lease. It fails if the lease was taken over by another
+// run in the meantime.
+func (l *Lock) Renew(ctx context.Context) error {
+	leases := l.c.CS.CoordinationV1().Leases(l.namespace)
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !l.owns(lease) {
+		return fmt.Errorf("%w: lease %s/%s was taken over by %s", ErrLockLost, l.namespace, LockName, holderOf(lease))
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// KeepAlive renews the lease every interval in the background until Release
+// is called. Once the lease is taken over, or renewals keep failing until it
+// would expire before the next attempt, it stops and closes Lost.
+func (l *Lock) KeepAlive(interval time.Duration) {
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				err := l.Renew(context.Background())
+				switch {
+				case err == nil:
+					renewed = time.Now()
+					continue
+				case errors.Is(err, ErrLockLost):
+				case time.Since(renewed)+interval >= l.duration:
+					err = fmt.Errorf("%w: lease %s/%s not renewed since %s: %v", ErrLockLost, l.namespace, LockName, renewed.Format(time.RFC3339), err)
+				default:
+					if l.c.logger != nil {
+						l.c.logger.Warn("Failed to renew lock", "lease", l.namespace+"/"+LockName, "error", err)
+					}
+					continue
+				}
+				l.lostErr = err
+				close(l.lost)
+				return
+			}
+		}
+	}()
+}
+
+// Lost is closed once KeepAlive gave up on the lease; Err then says why.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Err returns why the lease was lost, or nil while it is held.
+func (l *Lock) Err() error {
+	select {
+	case <-l.lost:
+		return l.lostErr
+	default:
+		return nil
+	}
+}
+
+// Release stops renewing the lease and deletes it if this run still holds it.
+func (l *Lock) Release(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+			<-l.done
+		}
+	})
+	leases := l.c.CS.CoordinationV1().Leases(l.namespace)
+	lease, err := leases.Get(ctx, LockName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !l.owns(lease) {
+		return nil
+	}
+	return leases.Delete(ctx, LockName, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+}
+
+func (l *Lock) owns(lease *coordinationv1.Lease) bool {
+	return holderOf(lease) == l.identity && lease.Annotations[AnnotationRunID] == l.runID
+}
+
+func holderOf(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
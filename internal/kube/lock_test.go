@@ -0,0 +1,158 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func existingLease(holder, runID string, renewed time.Time) *coordinationv1.Lease {
+	seconds := int32(60)
+	renew := metav1.NewMicroTime(renewed)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: LockName, Namespace: DefaultLockNamespace, Annotations: map[string]string{AnnotationRunID: runID}},
+		Spec:       coordinationv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &seconds, RenewTime: &renew},
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *coordinationv1.Lease
+		force    bool
+		wantHeld bool
+	}{
+		{name: "no lease"},
+		{name: "held by another run", existing: existingLease("bob@ci", "run-b", time.Now()), wantHeld: true},
+		{name: "held but forced", existing: existingLease("bob@ci", "run-b", time.Now()), force: true},
+		{name: "expired lease", existing: existingLease("bob@ci", "run-b", time.Now().Add(-2*time.Minute))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset()
+			if tt.existing != nil {
+				cs = fake.NewSimpleClientset(tt.existing)
+			}
+			client := &Client{CS: cs}
+			ctx := context.Background()
+
+			lock, err := client.AcquireLock(ctx, DefaultLockNamespace, "alice@laptop", "run-a", DefaultLockDuration, tt.force)
+			var held *LockHeldError
+			if tt.wantHeld {
+				if !errors.As(err, &held) || held.Holder != "bob@ci" || held.RunID != "run-b" {
+					t.Fatalf("AcquireLock() error = %v, want LockHeldError for bob@ci", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AcquireLock() error = %v", err)
+			}
+
+			lease, err := cs.CoordinationV1().Leases(DefaultLockNamespace).Get(ctx, LockName, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if holderOf(lease) != "alice@laptop" || lease.Annotations[AnnotationRunID] != "run-a" {
+				t.Errorf("unexpected lease holder %q run %q", holderOf(lease), lease.Annotations[AnnotationRunID])
+			}
+			if err := lock.Renew(ctx); err != nil {
+				t.Errorf("Renew() error = %v", err)
+			}
+
+			if err := lock.Release(ctx); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+			if _, err := cs.CoordinationV1().Leases(DefaultLockNamespace).Get(ctx, LockName, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("Expected lease to be deleted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLockTakenOver(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	client := &Client{CS: cs}
+	ctx := context.Background()
+
+	lock, err := client.AcquireLock(ctx, DefaultLockNamespace, "alice@laptop", "run-a", DefaultLockDuration, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AcquireLock(ctx, DefaultLockNamespace, "bob@ci", "run-b", DefaultLockDuration, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := lock.Renew(ctx); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Renew() error = %v, want ErrLockLost after the lease was taken over", err)
+	}
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	lease, err := cs.CoordinationV1().Leases(DefaultLockNamespace).Get(ctx, LockName, metav1.GetOptions{})
+	if err != nil || holderOf(lease) != "bob@ci" {
+		t.Errorf("Release() must not remove another run's lease, got %v, %v", lease, err)
+	}
+}
+
+func TestKeepAliveReportsLoss(t *testing.T) {
+	tests := []struct {
+		name      string
+		breakLock func(client *Client, failing *atomic.Bool)
+	}{
+		{
+			name: "taken over",
+			breakLock: func(client *Client, _ *atomic.Bool) {
+				if _, err := client.AcquireLock(context.Background(), DefaultLockNamespace, "bob@ci", "run-b", DefaultLockDuration, true); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:      "renewals failing",
+			breakLock: func(_ *Client, failing *atomic.Bool) { failing.Store(true) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := fake.NewSimpleClientset()
+			var failing atomic.Bool
+			cs.PrependReactor("get", "leases", func(k8stesting.Action) (bool, runtime.Object, error) {
+				if failing.Load() {
+					return true, nil, errors.New("connection refused")
+				}
+				return false, nil, nil
+			})
+			client := &Client{CS: cs}
+			lock, err := client.AcquireLock(context.Background(), DefaultLockNamespace, "alice@laptop", "run-a", 50*time.Millisecond, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lock.KeepAlive(10 * time.Millisecond)
+			defer func() { _ = lock.Release(context.Background()) }()
+			if lock.Err() != nil {
+				t.Fatalf("Err() = %v while the lease is held", lock.Err())
+			}
+
+			tt.breakLock(client, &failing)
+			select {
+			case <-lock.Lost():
+			case <-time.After(time.Second):
+				t.Fatal("Lost() was not closed")
+			}
+			if !errors.Is(lock.Err(), ErrLockLost) {
+				t.Errorf("Err() = %v, want ErrLockLost", lock.Err())
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workload is a controller owning evicted pods.
+type Workload struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (w Workload) String() string {
+	return w.Kind + "/" + w.Namespace + "/" + w.Name
+}
+
+// WorkloadsOf resolves the controllers owning the evictable pods among pods:
+// Deployments (through their ReplicaSets), StatefulSets and bare
+// ReplicaSets. Pods owned by anything else, such as Jobs, are ignored.
+func (c *Client) WorkloadsOf(ctx context.Context, pods []corev1.Pod) ([]Workload, error) {
+	seen := map[Workload]bool{}
+	var out []Workload
+	add := func(w Workload) {
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	for i := range pods {
+		p := &pods[i]
+		if c.shouldSkipPod(p) {
+			continue
+		}
+		owner := metav1.GetControllerOf(p)
+		if owner == nil {
+			continue
+		}
+		switch owner.Kind {
+		case "StatefulSet":
+			add(Workload{Kind: "StatefulSet", Namespace: p.Namespace, Name: owner.Name})
+		case "ReplicaSet":
+			rs, err := c.CS.AppsV1().ReplicaSets(p.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if d := metav1.GetControllerOf(rs); d != nil && d.Kind == "Deployment" {
+				add(Workload{Kind: "Deployment", Namespace: p.Namespace, Name: d.Name})
+			} else {
+				add(Workload{Kind: "ReplicaSet", Namespace: p.Namespace, Name: owner.Name})
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].String() < out[j].String() })
+	return out, nil
+}
+
+// WorkloadAvailable reports whether w has all its desired replicas
+// available (ready, for StatefulSets). A workload that no longer exists
+// counts as available.
+func (c *Client) WorkloadAvailable(ctx context.Context, w Workload) (bool, error) {
+	var desired, ready int32 = 1, 0
+	switch w.Kind {
+	case "Deployment":
+		d, err := c.CS.AppsV1().Deployments(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		ready = d.Status.AvailableReplicas
+	case "StatefulSet":
+		s, err := c.CS.AppsV1().StatefulSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if s.Spec.Replicas != nil {
+			desired = *s.Spec.Replicas
+		}
+		ready = s.Status.ReadyReplicas
+	case "ReplicaSet":
+		rs, err := c.CS.AppsV1().ReplicaSets(w.Namespace).Get(ctx, w.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if rs.Spec.Replicas != nil {
+			desired = *rs.Spec.Replicas
+		}
+		ready = rs.Status.AvailableReplicas
+	default:
+		return false, fmt.Errorf("unsupported workload kind %q", w.Kind)
+	}
+	return ready >= desired, nil
+}
+
+// WaitForWorkloads polls until every workload is available or timeout
+// passes, in which case the error names the workloads still short.
+func (c *Client) WaitForWorkloads(ctx context.Context, workloads []Workload, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	pending := workloads
+	for {
+		var still []Workload
+		for _, w := range pending {
+			ok, err := c.WorkloadAvailable(ctx, w)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				still = append(still, w)
+			}
+		}
+		if len(still) == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			names := make([]string, len(still))
+			for i, w := range still {
+				names[i] = w.String()
+			}
+			return fmt.Errorf("workloads not available within timeout: %s", strings.Join(names, ", "))
+		}
+		pending = still
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
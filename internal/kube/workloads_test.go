@@ -0,0 +1,92 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func controllerRef(kind, name string) []metav1.OwnerReference {
+	yes := true
+	return []metav1.OwnerReference{{Kind: kind, Name: name, Controller: &yes}}
+}
+
+func TestWorkloadsOf(t *testing.T) {
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "web-7d9c", Namespace: "default", OwnerReferences: controllerRef("Deployment", "web")}}
+	bare := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "default"}}
+	client := &Client{CS: fake.NewSimpleClientset(rs, bare)}
+
+	pod := func(name, kind, owner string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", OwnerReferences: controllerRef(kind, owner)}}
+	}
+	pods := []corev1.Pod{
+		pod("web-7d9c-a", "ReplicaSet", "web-7d9c"),
+		pod("web-7d9c-b", "ReplicaSet", "web-7d9c"),
+		pod("db-0", "StatefulSet", "db"),
+		pod("legacy-x", "ReplicaSet", "legacy"),
+		pod("fluentd-abc", "DaemonSet", "fluentd"),
+		pod("backup-123", "Job", "backup"),
+		{ObjectMeta: metav1.ObjectMeta{Name: "naked", Namespace: "default"}},
+	}
+
+	got, err := client.WorkloadsOf(context.Background(), pods)
+	if err != nil {
+		t.Fatalf("WorkloadsOf() error = %v", err)
+	}
+	var names []string
+	for _, w := range got {
+		names = append(names, w.String())
+	}
+	want := "Deployment/default/web,ReplicaSet/default/legacy,StatefulSet/default/db"
+	if strings.Join(names, ",") != want {
+		t.Errorf("WorkloadsOf() = %v, want %s", names, want)
+	}
+}
+
+func TestWaitForWorkloads(t *testing.T) {
+	tests := []struct {
+		name      string
+		available int32
+		wantErr   bool
+	}{
+		{name: "available", available: 3},
+		{name: "short of replicas", available: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+				Status:     appsv1.DeploymentStatus{AvailableReplicas: tt.available},
+			}
+			sts := &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec:       appsv1.StatefulSetSpec{Replicas: int32Ptr(1)},
+				Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+			}
+			client := &Client{CS: fake.NewSimpleClientset(d, sts)}
+			workloads := []Workload{
+				{Kind: "Deployment", Namespace: "default", Name: "web"},
+				{Kind: "StatefulSet", Namespace: "default", Name: "db"},
+				{Kind: "Deployment", Namespace: "default", Name: "deleted"},
+			}
+
+			err := client.WaitForWorkloads(context.Background(), workloads, 10*time.Millisecond, time.Millisecond)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WaitForWorkloads() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "Deployment/default/web") {
+				t.Errorf("error should name the unavailable workload, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package metrics exposes rollout progress as Prometheus metrics, either on
+// an HTTP listener or as a node_exporter textfile.
+package metrics
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "kubectl_reboot"
+
+// durationBuckets spans a quick cordon up to a slow bare-metal boot.
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800}
+
+// Metrics records rollout progress. It implements report.Observer, so the
+// values are derived from the phases recorded for each node.
+type Metrics struct {
+	registry *prometheus.Registry
+	textfile string
+
+	targetNodes     prometheus.Gauge
+	nodesProcessed  *prometheus.GaugeVec
+	phaseDuration   *prometheus.HistogramVec
+	rebootToBootID  prometheus.Histogram
+	rebootToReady   prometheus.Histogram
+	podsEvicted     prometheus.Counter
+	evictionRetries prometheus.Counter
+
+	mu sync.Mutex
+	// results holds the latest result of each node, so that a node retried
+	// with --interactive moves between results instead of counting twice.
+	results map[string]report.Status
+}
+
+// New returns Metrics registered on a dedicated registry. If textfile is
+// set, the metrics are written to it each time a node finishes.
+func New(textfile string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		textfile: textfile,
+		results:  map[string]report.Status{},
+		targetNodes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "target_nodes",
+			Help:      "Number of nodes targeted by the run.",
+		}),
+		nodesProcessed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "nodes_processed",
+			Help:      "Nodes processed, by their latest result (succeeded or failed).",
+		}, []string{"result"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "Duration of each node phase that ran.",
+			Buckets:   durationBuckets,
+		}, []string{"phase"}),
+		rebootToBootID: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reboot_to_boot_id_seconds",
+			Help:      "Time from triggering the reboot until the node reported a new boot ID.",
+			Buckets:   durationBuckets,
+		}),
+		rebootToReady: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reboot_to_ready_seconds",
+			Help:      "Time from triggering the reboot until the node was Ready again.",
+			Buckets:   durationBuckets,
+		}),
+		podsEvicted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pods_evicted_total",
+			Help:      "Pods evicted while draining nodes.",
+		}),
+		evictionRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "eviction_retries_total",
+			Help:      "Evictions re-sent and accepted for pods still running on a draining node.",
+		}),
+	}
+	m.nodesProcessed.WithLabelValues(string(report.StatusSucceeded))
+	m.nodesProcessed.WithLabelValues(string(report.StatusFailed))
+	m.registry.MustRegister(m.targetNodes, m.nodesProcessed, m.phaseDuration,
+		m.rebootToBootID, m.rebootToReady, m.podsEvicted, m.evictionRetries)
+	return m
+}
+
+// Registry returns the registry holding the rollout metrics.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// SetTargetNodes records how many nodes the run will process.
+func (m *Metrics) SetTargetNodes(n int) {
+	m.targetNodes.Set(float64(n))
+}
+
+// PhaseEnded observes the duration of phases that ran.
+func (m *Metrics) PhaseEnded(_ string, p *report.PhaseRecord) {
+	if p.Status == report.StatusSkipped {
+		return
+	}
+	m.phaseDuration.WithLabelValues(string(p.Name)).Observe(p.Duration().Seconds())
+}
+
+// NodeFinished counts the node under its result, moving a retried node from
+// its earlier result, adds its evictions and observes the reboot timings,
+// then refreshes the textfile if one is configured.
+func (m *Metrics) NodeFinished(n *report.NodeReport) {
+	m.mu.Lock()
+	if prev, ok := m.results[n.Name]; ok {
+		m.nodesProcessed.WithLabelValues(string(prev)).Dec()
+	}
+	m.results[n.Name] = n.Status
+	m.nodesProcessed.WithLabelValues(string(n.Status)).Inc()
+	m.mu.Unlock()
+	m.podsEvicted.Add(float64(len(n.PodsEvicted)))
+	m.evictionRetries.Add(float64(n.EvictionRetries))
+
+	if reboot := n.Phase(report.PhaseReboot); reboot != nil && reboot.Status == report.StatusSucceeded {
+		if d, ok := sinceStart(reboot, n.Phase(report.PhaseWaitBootID)); ok {
+			m.rebootToBootID.Observe(d.Seconds())
+		}
+		if d, ok := sinceStart(reboot, n.Phase(report.PhaseWaitReady)); ok {
+			m.rebootToReady.Observe(d.Seconds())
+		}
+	}
+	_ = m.WriteTextfile()
+}
+
+// sinceStart returns the time from the start of from until the successful
+// end of to.
+func sinceStart(from, to *report.PhaseRecord) (time.Duration, bool) {
+	if to == nil || to.Status != report.StatusSucceeded || to.FinishedAt == nil {
+		return 0, false
+	}
+	return to.FinishedAt.Sub(from.StartedAt), true
+}
+
+// WriteTextfile writes the metrics in the node_exporter textfile format. It
+// is a no-op when no textfile was configured.
+func (m *Metrics) WriteTextfile() error {
+	if m.textfile == "" {
+		return nil
+	}
+	return prometheus.WriteToTextfile(m.textfile, m.registry)
+}
+
+// Serve exposes the metrics on addr at /metrics until ctx is cancelled. The
+// listener is opened before Serve returns so bind errors are reported
+// immediately.
+func (m *Metrics) Serve(ctx context.Context, addr string) (net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() { _ = srv.Serve(ln) }()
+	return ln.Addr(), nil
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsObserveReport(t *testing.T) {
+	m := New("")
+	r := report.New(false)
+	r.AddObserver(m)
+
+	ok := r.Node("node1")
+	ok.StartPhase(report.PhaseDrain).Succeed()
+	ok.AddEvictedPods([]string{"default/web-1", "default/web-2"}, 1)
+	ok.StartPhase(report.PhaseReboot).Succeed()
+	ok.StartPhase(report.PhaseWaitBootID).Succeed()
+	ok.StartPhase(report.PhaseWaitReady).Succeed()
+	ok.SkipPhase(report.PhaseUncordon, "test")
+	ok.Finish(nil)
+
+	bad := r.Node("node2")
+	_ = bad.StartPhase(report.PhaseDrain).Fail(errors.New("boom"))
+	bad.Finish(errors.New("boom"))
+
+	if got := testutil.ToFloat64(m.nodesProcessed.WithLabelValues("succeeded")); got != 1 {
+		t.Errorf("succeeded = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.nodesProcessed.WithLabelValues("failed")); got != 1 {
+		t.Errorf("failed = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.podsEvicted); got != 2 {
+		t.Errorf("pods evicted = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.evictionRetries); got != 1 {
+		t.Errorf("eviction retries = %v, want 1", got)
+	}
+
+	// Skipped phases are not observed.
+	if n := testutil.CollectAndCount(m.phaseDuration); n != 4 {
+		t.Errorf("phase duration series = %d, want 4", n)
+	}
+	if n := testutil.CollectAndCount(m.rebootToReady); n != 1 {
+		t.Errorf("reboot-to-ready series = %d, want 1", n)
+	}
+}
+
+func TestMetricsRetriedNode(t *testing.T) {
+	m := New("")
+	r := report.New(false)
+	r.AddObserver(m)
+
+	r.Node("node1").Finish(errors.New("boom"))
+	retried := r.Node("node1")
+	retried.AddEvictedPods([]string{"default/web-1"}, 0)
+	retried.Finish(nil)
+
+	if got := testutil.ToFloat64(m.nodesProcessed.WithLabelValues("succeeded")); got != 1 {
+		t.Errorf("succeeded = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.nodesProcessed.WithLabelValues("failed")); got != 0 {
+		t.Errorf("failed = %v, want 0", got)
+	}
+	// The evictions of the retry did happen.
+	if got := testutil.ToFloat64(m.podsEvicted); got != 1 {
+		t.Errorf("pods evicted = %v, want 1", got)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubectl_reboot.prom")
+	m := New(path)
+	m.SetTargetNodes(3)
+	if err := m.WriteTextfile(); err != nil {
+		t.Fatalf("WriteTextfile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "kubectl_reboot_target_nodes 3") {
+		t.Errorf("unexpected textfile contents:\n%s", data)
+	}
+}
+
+func TestServe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := New("")
+	m.SetTargetNodes(2)
+	addr, err := m.Serve(ctx, "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "kubectl_reboot_target_nodes 2") {
+		t.Errorf("unexpected /metrics body:\n%s", body)
+	}
+}
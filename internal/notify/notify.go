@@ -0,0 +1,222 @@
+// Package notify posts run and node lifecycle notifications to webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+	"github.com/charmbracelet/log"
+)
+
+type Event string
+
+const (
+	EventRunStarted    Event = "run.started"
+	EventNodeSucceeded Event = "node.succeeded"
+	EventNodeFailed    Event = "node.failed"
+	EventRunFinished   Event = "run.finished"
+)
+
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+)
+
+// Payload is the body posted to generic webhooks.
+type Payload struct {
+	Event     Event     `json:"event"`
+	RunID     string    `json:"runID,omitempty"`
+	Time      time.Time `json:"time"`
+	Cluster   string    `json:"cluster,omitempty"`
+	Operator  string    `json:"operator,omitempty"`
+	DryRun    bool      `json:"dryRun"`
+	Nodes     []string  `json:"nodes,omitempty"`
+	Node      string    `json:"node,omitempty"`
+	Aborted   bool      `json:"aborted,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	OnFailure string    `json:"onFailure,omitempty"`
+	// Retry numbers a repeated attempt at Node. Its result replaces the one
+	// announced for the earlier attempt.
+	Retry  int            `json:"retry,omitempty"`
+	Totals *report.Totals `json:"totals,omitempty"`
+}
+
+// Text renders the payload as a one-line human readable message.
+func (p *Payload) Text() string {
+	prefix := "kubectl-reboot"
+	if p.Cluster != "" {
+		prefix += " [" + p.Cluster + "]"
+	}
+	if p.DryRun {
+		prefix += " (dry-run)"
+	}
+	switch p.Event {
+	case EventRunStarted:
+		return fmt.Sprintf("%s: %s started rebooting %d node(s): %s", prefix, valueOr(p.Operator, "someone"), len(p.Nodes), strings.Join(p.Nodes, ", "))
+	case EventNodeSucceeded:
+		return fmt.Sprintf("%s: node %s rebooted successfully%s", prefix, p.Node, p.retryNote())
+	case EventNodeFailed:
+		msg := fmt.Sprintf("%s: node %s failed%s: %s", prefix, p.Node, p.retryNote(), p.Error)
+		if p.OnFailure != "" {
+			msg += " (" + p.OnFailure + ")"
+		}
+		return msg
+	case EventRunFinished:
+		if p.Totals == nil {
+			return prefix + ": run finished"
+		}
+		verb := "finished"
+		if p.Aborted {
+			verb = "aborted"
+		}
+		msg := fmt.Sprintf("%s: run %s, %d/%d node(s) succeeded", prefix, verb, p.Totals.Succeeded, p.Totals.Nodes)
+		if p.Totals.Failed > 0 {
+			msg += fmt.Sprintf(", %d failed: %s", p.Totals.Failed, strings.Join(p.Nodes, ", "))
+		}
+		if p.Totals.NotAttempted > 0 {
+			msg += fmt.Sprintf(", %d not attempted", p.Totals.NotAttempted)
+		}
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", prefix, p.Event)
+}
+
+func (p *Payload) retryNote() string {
+	if p.Retry == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" on retry %d", p.Retry)
+}
+
+// Webhook is a URL notifications are posted to, in the given format.
+type Webhook struct {
+	URL    string
+	Format string
+}
+
+// body encodes p in the webhook's format.
+func (w Webhook) body(p *Payload) ([]byte, error) {
+	if w.Format == FormatSlack {
+		return json.Marshal(map[string]string{"text": p.Text()})
+	}
+	return json.Marshal(p)
+}
+
+// Notifier posts lifecycle notifications to its webhooks. It implements
+// report.Observer for the per-node notifications; failures to deliver are
+// logged and never fail the run.
+type Notifier struct {
+	Webhooks []Webhook
+	RunID    string
+	Cluster  string
+	Operator string
+	DryRun   bool
+	// Retries is how many times a failed delivery is retried, waiting
+	// Backoff, then twice as long, and so on between attempts.
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+	Logger  *log.Logger
+}
+
+// RunStarted announces the nodes about to be rebooted.
+func (n *Notifier) RunStarted(nodes []string) {
+	n.Notify(&Payload{Event: EventRunStarted, Nodes: nodes})
+}
+
+// RunFinished announces the totals of the run, the nodes that failed and
+// whether the run was aborted before attempting every node.
+func (n *Notifier) RunFinished(totals report.Totals, failed []string, aborted bool) {
+	n.Notify(&Payload{Event: EventRunFinished, Totals: &totals, Nodes: failed, Aborted: aborted})
+}
+
+// PhaseEnded implements report.Observer; phases are not announced.
+func (n *Notifier) PhaseEnded(string, *report.PhaseRecord) {}
+
+// NodeFinished implements report.Observer and announces the node's result.
+// The result of a retried node is marked as a retry, so that receivers
+// update the node instead of counting it again.
+func (n *Notifier) NodeFinished(nr *report.NodeReport) {
+	p := &Payload{Event: EventNodeSucceeded, Node: nr.Name, Retry: nr.Retries}
+	if nr.Status == report.StatusFailed {
+		p.Event = EventNodeFailed
+		p.Error = nr.Error
+		p.OnFailure = nr.OnFailure
+	}
+	n.Notify(p)
+}
+
+// Notify fills in the run-wide fields of p and posts it to every webhook.
+func (n *Notifier) Notify(p *Payload) {
+	p.Time = time.Now().UTC()
+	p.RunID = n.RunID
+	p.Cluster = n.Cluster
+	p.Operator = n.Operator
+	p.DryRun = n.DryRun
+	for _, w := range n.Webhooks {
+		if err := n.send(w, p); err != nil {
+			n.warn("Webhook notification failed", "event", p.Event, "format", w.Format, "error", err)
+		}
+	}
+}
+
+func (n *Notifier) send(w Webhook, p *Payload) error {
+	body, err := w.body(p)
+	if err != nil {
+		return err
+	}
+	backoff := n.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(w.URL, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.Retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post delivers body once and reports whether a failure is worth retrying.
+func (n *Notifier) post(url string, body []byte) (bool, error) {
+	client := n.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+func (n *Notifier) warn(msg string, kv ...interface{}) {
+	if n.Logger != nil {
+		n.Logger.Warn(msg, kv...)
+	}
+}
+
+func valueOr(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ayetkin/kubectl-reboot/internal/report"
+)
+
+// recorder is a webhook endpoint that fails the first failures requests.
+type recorder struct {
+	mu       sync.Mutex
+	failures int
+	bodies   []string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.failures > 0 {
+		rec.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	rec.bodies = append(rec.bodies, string(body))
+}
+
+func TestNotifierLifecycle(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n := &Notifier{Webhooks: []Webhook{{URL: srv.URL, Format: FormatGeneric}}, Cluster: "prod", Operator: "alice@laptop"}
+	r := report.New(false)
+	r.AddObserver(n)
+
+	n.RunStarted([]string{"node1", "node2"})
+	r.Node("node1").Finish(nil)
+	r.Node("node2").Finish(errors.New("evict: timeout"))
+	r.Finish()
+	n.RunFinished(r.Totals, []string{"node2"}, false)
+
+	if len(rec.bodies) != 4 {
+		t.Fatalf("Expected 4 notifications, got %d", len(rec.bodies))
+	}
+	wantEvents := []Event{EventRunStarted, EventNodeSucceeded, EventNodeFailed, EventRunFinished}
+	for i, body := range rec.bodies {
+		var p Payload
+		if err := json.Unmarshal([]byte(body), &p); err != nil {
+			t.Fatalf("invalid payload %q: %v", body, err)
+		}
+		if p.Event != wantEvents[i] || p.Cluster != "prod" || p.Operator != "alice@laptop" {
+			t.Errorf("notification %d = %+v, want event %s", i, p, wantEvents[i])
+		}
+	}
+	var failed Payload
+	_ = json.Unmarshal([]byte(rec.bodies[2]), &failed)
+	if failed.Node != "node2" || failed.Error != "evict: timeout" {
+		t.Errorf("unexpected failure payload %+v", failed)
+	}
+}
+
+func TestNotifierMarksRetries(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n := &Notifier{Webhooks: []Webhook{{URL: srv.URL, Format: FormatGeneric}}}
+	r := report.New(false)
+	r.AddObserver(n)
+
+	r.Node("node1").Finish(errors.New("boom"))
+	r.Node("node1").Finish(nil)
+
+	if len(rec.bodies) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d", len(rec.bodies))
+	}
+	var first, retry Payload
+	_ = json.Unmarshal([]byte(rec.bodies[0]), &first)
+	_ = json.Unmarshal([]byte(rec.bodies[1]), &retry)
+	if first.Event != EventNodeFailed || first.Retry != 0 {
+		t.Errorf("unexpected first payload %+v", first)
+	}
+	if retry.Event != EventNodeSucceeded || retry.Retry != 1 {
+		t.Errorf("unexpected retry payload %+v", retry)
+	}
+	if got := retry.Text(); !strings.Contains(got, "node node1 rebooted successfully on retry 1") {
+		t.Errorf("unexpected retry text %q", got)
+	}
+}
+
+func TestNotifierSlackFormat(t *testing.T) {
+	rec := &recorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	n := &Notifier{Webhooks: []Webhook{{URL: srv.URL, Format: FormatSlack}}, Cluster: "prod"}
+	n.Notify(&Payload{Event: EventNodeFailed, Node: "node2", Error: "boom"})
+
+	if len(rec.bodies) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(rec.bodies))
+	}
+	var msg map[string]string
+	if err := json.Unmarshal([]byte(rec.bodies[0]), &msg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msg["text"], "node node2 failed: boom") || !strings.Contains(msg["text"], "[prod]") {
+		t.Errorf("unexpected slack text %q", msg["text"])
+	}
+}
+
+func TestNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		retries   int
+		delivered int
+	}{
+		{name: "succeeds after retries", failures: 2, retries: 2, delivered: 1},
+		{name: "gives up", failures: 3, retries: 2, delivered: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{failures: tt.failures}
+			srv := httptest.NewServer(rec)
+			defer srv.Close()
+
+			n := &Notifier{Webhooks: []Webhook{{URL: srv.URL}}, Retries: tt.retries, Backoff: time.Millisecond}
+			n.RunStarted([]string{"node1"})
+			if len(rec.bodies) != tt.delivered {
+				t.Errorf("delivered %d notifications, want %d", len(rec.bodies), tt.delivered)
+			}
+		})
+	}
+}
+
+func TestNotifierDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	n := &Notifier{Webhooks: []Webhook{{URL: srv.URL}}, Retries: 3, Backoff: time.Millisecond}
+	n.RunStarted([]string{"node1"})
+	if calls != 1 {
+		t.Errorf("Expected 1 attempt for a 400 response, got %d", calls)
+	}
+}
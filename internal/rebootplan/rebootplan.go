@@ -0,0 +1,175 @@
+// Package rebootplan defines the NodeRebootPlan custom resource reconciled
+// by controller mode, and reads and updates it through the dynamic client.
+package rebootplan
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	Group    = "kubectl-reboot.io"
+	Version  = "v1alpha1"
+	Kind     = "NodeRebootPlan"
+	Resource = "noderebootplans"
+)
+
+// GVR identifies the cluster-scoped NodeRebootPlan resource.
+var GVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: Resource}
+
+// Phases of a plan.
+const (
+	PhasePending   = "Pending"
+	PhaseWaiting   = "Waiting"
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+	PhaseAborted   = "Aborted"
+	PhaseSuspended = "Suspended"
+)
+
+// NodePending is the status of a node waiting for its batch.
+const NodePending = "pending"
+
+// NodeRebootPlan asks the controller to restart a set of nodes.
+type NodeRebootPlan struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   Spec   `json:"spec"`
+	Status Status `json:"status,omitempty"`
+}
+
+// Spec selects the nodes and says how to restart them. Unset fields fall
+// back to the controller's flags.
+type Spec struct {
+	// NodeSelector and Nodes are combined; at least one must be set.
+	NodeSelector        *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	Nodes               []string              `json:"nodes,omitempty"`
+	ExcludeNodes        []string              `json:"excludeNodes,omitempty"`
+	ExcludeControlPlane bool                  `json:"excludeControlPlane,omitempty"`
+	Strategy            Strategy              `json:"strategy,omitempty"`
+	// Windows are maintenance windows in --window syntax; nodes are only
+	// started while one of them is open.
+	Windows     []string `json:"windows,omitempty"`
+	HealthGates []string `json:"healthGates,omitempty"`
+	// RebootMethod, RebootCommand, PreRebootCommand and PostRebootCommand
+	// match the flags of the same name.
+	RebootMethod      string `json:"rebootMethod,omitempty"`
+	RebootCommand     string `json:"rebootCommand,omitempty"`
+	PreRebootCommand  string `json:"preRebootCommand,omitempty"`
+	PostRebootCommand string `json:"postRebootCommand,omitempty"`
+	DryRun            bool   `json:"dryRun,omitempty"`
+	// Suspend pauses the plan before its next batch.
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// Strategy controls the pace of the rollout.
+type Strategy struct {
+	BatchSize     int               `json:"batchSize,omitempty"`
+	MaxFailures   *int              `json:"maxFailures,omitempty"`
+	CapacityCheck string            `json:"capacityCheck,omitempty"`
+	WaitWorkloads bool              `json:"waitWorkloads,omitempty"`
+	OnFailure     map[string]string `json:"onFailure,omitempty"`
+}
+
+// Status reports the progress of a plan.
+type Status struct {
+	Phase              string       `json:"phase,omitempty"`
+	Message            string       `json:"message,omitempty"`
+	ObservedGeneration int64        `json:"observedGeneration,omitempty"`
+	RunID              string       `json:"runID,omitempty"`
+	StartedAt          *metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt         *metav1.Time `json:"finishedAt,omitempty"`
+	Nodes              []NodeStatus `json:"nodes,omitempty"`
+}
+
+// NodeStatus is the progress of one node of the plan.
+type NodeStatus struct {
+	Name string `json:"name"`
+	// Status is pending or a run report status: running, succeeded,
+	// failed, skipped or not-attempted.
+	Status     string       `json:"status"`
+	Phase      string       `json:"phase,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	StartedAt  *metav1.Time `json:"startedAt,omitempty"`
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+}
+
+// Done reports whether the plan reached a phase it never leaves.
+func (p *NodeRebootPlan) Done() bool {
+	switch p.Status.Phase {
+	case PhaseSucceeded, PhaseFailed, PhaseAborted:
+		return true
+	}
+	return false
+}
+
+// Node returns the status of the named node, adding it if needed.
+func (s *Status) Node(name string) *NodeStatus {
+	for i := range s.Nodes {
+		if s.Nodes[i].Name == name {
+			return &s.Nodes[i]
+		}
+	}
+	s.Nodes = append(s.Nodes, NodeStatus{Name: name})
+	return &s.Nodes[len(s.Nodes)-1]
+}
+
+// Count returns the number of nodes with the given status.
+func (s *Status) Count(status string) int {
+	n := 0
+	for _, ns := range s.Nodes {
+		if ns.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// List returns the plans in the cluster, oldest first.
+func List(ctx context.Context, dyn dynamic.Interface) ([]*NodeRebootPlan, error) {
+	list, err := dyn.Resource(GVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	plans := make([]*NodeRebootPlan, 0, len(list.Items))
+	for i := range list.Items {
+		p, err := FromUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].CreationTimestamp.Before(&plans[j].CreationTimestamp)
+	})
+	return plans, nil
+}
+
+// FromUnstructured converts a plan read through the dynamic client.
+func FromUnstructured(u *unstructured.Unstructured) (*NodeRebootPlan, error) {
+	p := &NodeRebootPlan{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// UpdateStatus replaces the status of the named plan.
+func UpdateStatus(ctx context.Context, dyn dynamic.Interface, name string, status Status) error {
+	patch, err := json.Marshal(map[string]any{"status": status})
+	if err != nil {
+		return err
+	}
+	_, err = dyn.Resource(GVR).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
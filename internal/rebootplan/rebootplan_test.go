@@ -0,0 +1,85 @@
+package rebootplan
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestStatusNodes(t *testing.T) {
+	var s Status
+	s.Node("a").Status = "failed"
+	s.Node("b").Status = "succeeded"
+	s.Node("a").Phase = "drain"
+	if len(s.Nodes) != 2 || s.Nodes[0].Phase != "drain" {
+		t.Fatalf("unexpected nodes %+v", s.Nodes)
+	}
+	if s.Count("failed") != 1 || s.Count("running") != 0 {
+		t.Errorf("Count() wrong for %+v", s.Nodes)
+	}
+}
+
+func TestDone(t *testing.T) {
+	tests := map[string]bool{
+		"":             false,
+		PhasePending:   false,
+		PhaseWaiting:   false,
+		PhaseRunning:   false,
+		PhaseSuspended: false,
+		PhaseSucceeded: true,
+		PhaseFailed:    true,
+		PhaseAborted:   true,
+	}
+	for phase, want := range tests {
+		p := &NodeRebootPlan{Status: Status{Phase: phase}}
+		if got := p.Done(); got != want {
+			t.Errorf("Done() with phase %q = %v, want %v", phase, got, want)
+		}
+	}
+}
+
+func TestListAndUpdateStatus(t *testing.T) {
+	plan := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": Group + "/" + Version,
+		"kind":       Kind,
+		"metadata":   map[string]any{"name": "workers"},
+		"spec": map[string]any{
+			"nodeSelector": map[string]any{"matchLabels": map[string]any{"pool": "workers"}},
+			"strategy":     map[string]any{"batchSize": int64(2), "maxFailures": int64(0)},
+			"windows":      []any{"Sat 00:00-06:00"},
+		},
+	}}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{GVR: Kind + "List"}, plan)
+	ctx := context.Background()
+
+	plans, err := List(ctx, dyn)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("List() returned %d plans", len(plans))
+	}
+	p := plans[0]
+	if p.Spec.NodeSelector.MatchLabels["pool"] != "workers" || p.Spec.Strategy.BatchSize != 2 ||
+		p.Spec.Strategy.MaxFailures == nil || *p.Spec.Strategy.MaxFailures != 0 || len(p.Spec.Windows) != 1 {
+		t.Errorf("unexpected spec %+v", p.Spec)
+	}
+
+	status := Status{Phase: PhaseRunning, RunID: "abc", Nodes: []NodeStatus{{Name: "w1", Status: "running", Phase: "drain"}}}
+	if err := UpdateStatus(ctx, dyn, "workers", status); err != nil {
+		t.Fatalf("UpdateStatus() error = %v", err)
+	}
+	plans, err = List(ctx, dyn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := plans[0].Status
+	if got.Phase != PhaseRunning || got.RunID != "abc" || len(got.Nodes) != 1 || got.Nodes[0].Phase != "drain" {
+		t.Errorf("status not updated: %+v", got)
+	}
+}
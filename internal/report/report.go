@@ -0,0 +1,548 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Phase names the steps of a node restart, in the order they run.
+type Phase string
+
+const (
+	PhaseCapacity Phase = "capacity-check"
+	PhaseCordon   Phase = "cordon"
+	PhaseDrain    Phase = "drain"
+	// PhaseWaitWorkloads waits after the drain for the evicted pods'
+	// controllers to be fully available again.
+	PhaseWaitWorkloads Phase = "wait-workloads"
+	// PhasePreReboot and PhasePostReboot run --pre-reboot-cmd and
+	// --post-reboot-cmd on the node.
+	PhasePreReboot Phase = "pre-reboot"
+	// PhaseSilence silences the node's alerts in Alertmanager.
+	PhaseSilence    Phase = "silence"
+	PhaseReboot     Phase = "reboot"
+	PhaseWaitBootID Phase = "wait-boot-id"
+	PhaseWaitReady  Phase = "wait-ready"
+	PhasePostReboot Phase = "post-reboot"
+	// PhaseHealthGates evaluates the --health-gate checks before uncordon.
+	PhaseHealthGates Phase = "health-gates"
+	PhaseUncordon    Phase = "uncordon"
+	// PhaseSettle waits for the same controllers again after uncordon,
+	// before the next node is started.
+	PhaseSettle Phase = "settle"
+)
+
+// HookPhase is the phase recording the --hook executables run for event.
+func HookPhase(event string) Phase {
+	return Phase("hook-" + event)
+}
+
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusSkipped   Status = "skipped"
+	// StatusNotAttempted marks nodes left untouched because the run was
+	// aborted before reaching them.
+	StatusNotAttempted Status = "not-attempted"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Report is the machine-readable result of a run.
+type Report struct {
+	RunID      string     `json:"runID,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	DryRun     bool       `json:"dryRun"`
+	Aborted    bool       `json:"aborted,omitempty"`
+	// WindowClosed is set when the run stopped because the maintenance
+	// window closed.
+	WindowClosed bool `json:"windowClosed,omitempty"`
+	// Canary is the soak of the --canary nodes, if any.
+	Canary *Canary       `json:"canary,omitempty"`
+	Nodes  []*NodeReport `json:"nodes"`
+	Totals Totals        `json:"totals"`
+
+	mu        sync.Mutex
+	observers []Observer
+}
+
+// Observer is notified as nodes move through their phases. Callbacks run
+// synchronously on the goroutine processing the node and must not retain
+// the records they are given.
+type Observer interface {
+	PhaseEnded(node string, p *PhaseRecord)
+	NodeFinished(n *NodeReport)
+}
+
+// Canary records whether the --canary nodes stayed healthy for the soak
+// period before the rest of the run was started.
+type Canary struct {
+	Nodes       []string   `json:"nodes"`
+	SoakSeconds float64    `json:"soakSeconds"`
+	Status      Status     `json:"status"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	SkipReason  string     `json:"skipReason,omitempty"`
+}
+
+type Totals struct {
+	Nodes           int `json:"nodes"`
+	Succeeded       int `json:"succeeded"`
+	Failed          int `json:"failed"`
+	PodsEvicted     int `json:"podsEvicted"`
+	EvictionRetries int `json:"evictionRetries"`
+	NotAttempted    int `json:"notAttempted,omitempty"`
+	Skipped         int `json:"skipped,omitempty"`
+}
+
+type NodeReport struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	// PreviouslyCordoned is set when the node was cordoned by someone else
+	// before the run and is therefore left cordoned.
+	PreviouslyCordoned bool       `json:"previouslyCordoned,omitempty"`
+	StartedAt          *time.Time `json:"startedAt,omitempty"`
+	FinishedAt         *time.Time `json:"finishedAt,omitempty"`
+	BootIDBefore       string     `json:"bootIDBefore,omitempty"`
+	BootIDAfter        string     `json:"bootIDAfter,omitempty"`
+	PodsEvicted        []string   `json:"podsEvicted,omitempty"`
+	EvictionRetries    int        `json:"evictionRetries,omitempty"`
+	SilenceID          string     `json:"silenceID,omitempty"`
+	Error              string     `json:"error,omitempty"`
+	OnFailure          string     `json:"onFailure,omitempty"`
+	// Retries counts the times the node was restarted again after failing.
+	Retries int            `json:"retries,omitempty"`
+	Phases  []*PhaseRecord `json:"phases"`
+
+	mu     sync.Mutex
+	report *Report
+}
+
+type PhaseRecord struct {
+	Name            Phase      `json:"name"`
+	Status          Status     `json:"status"`
+	StartedAt       time.Time  `json:"startedAt"`
+	FinishedAt      *time.Time `json:"finishedAt,omitempty"`
+	DurationSeconds float64    `json:"durationSeconds"`
+	Error           string     `json:"error,omitempty"`
+	SkipReason      string     `json:"skipReason,omitempty"`
+	// Output is the tail of a remote command's combined output.
+	Output string `json:"output,omitempty"`
+
+	node *NodeReport
+}
+
+func New(dryRun bool) *Report {
+	return &Report{StartedAt: time.Now().UTC(), DryRun: dryRun, Nodes: []*NodeReport{}}
+}
+
+// AddObserver registers o for phase and node notifications.
+func (r *Report) AddObserver(o Observer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observers = append(r.observers, o)
+}
+
+func (r *Report) observerList() []Observer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Observer(nil), r.observers...)
+}
+
+// Node adds a node to the report and marks it as running. A node restarted
+// again in the same run, as --interactive allows, replaces its earlier
+// record and counts the retry.
+func (r *Report) Node(name string) *NodeReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	nr := &NodeReport{Name: name, Status: StatusRunning, StartedAt: &now, Phases: []*PhaseRecord{}, report: r}
+	for i, old := range r.Nodes {
+		if old.Name == name {
+			nr.Retries = old.Retries + 1
+			r.Nodes[i] = nr
+			return nr
+		}
+	}
+	r.Nodes = append(r.Nodes, nr)
+	return nr
+}
+
+// Lookup returns the record of the named node, or nil if it is not in the
+// report.
+func (r *Report) Lookup(name string) *NodeReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, nr := range r.Nodes {
+		if nr.Name == name {
+			return nr
+		}
+	}
+	return nil
+}
+
+// NodeProgress is a point-in-time copy of a node's record for display while
+// the run is in progress.
+type NodeProgress struct {
+	Name       string
+	Status     Status
+	Phase      Phase // the phase running, or the last one recorded
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+	Evicted    int
+	Error      string
+}
+
+// Progress returns a snapshot of every node in the report, safe to take
+// while nodes are being processed.
+func (r *Report) Progress() []NodeProgress {
+	r.mu.Lock()
+	nodes := append([]*NodeReport(nil), r.Nodes...)
+	r.mu.Unlock()
+	out := make([]NodeProgress, 0, len(nodes))
+	for _, nr := range nodes {
+		nr.mu.Lock()
+		np := NodeProgress{
+			Name:       nr.Name,
+			Status:     nr.Status,
+			StartedAt:  nr.StartedAt,
+			FinishedAt: nr.FinishedAt,
+			Evicted:    len(nr.PodsEvicted),
+			Error:      nr.Error,
+		}
+		if len(nr.Phases) > 0 {
+			np.Phase = nr.Phases[len(nr.Phases)-1].Name
+		}
+		nr.mu.Unlock()
+		out = append(out, np)
+	}
+	return out
+}
+
+// SkipNodes records nodes the operator chose not to restart.
+func (r *Report) SkipNodes(names []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		r.Nodes = append(r.Nodes, &NodeReport{Name: name, Status: StatusSkipped, Phases: []*PhaseRecord{}, report: r})
+	}
+}
+
+// Abort marks the run as aborted and records the nodes that were never
+// started. Observers are not notified about them.
+func (r *Report) Abort(notAttempted []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Aborted = true
+	r.addNotAttempted(notAttempted)
+}
+
+// CloseWindow records that the maintenance window closed before the given
+// nodes could be started.
+func (r *Report) CloseWindow(notAttempted []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.WindowClosed = true
+	r.addNotAttempted(notAttempted)
+}
+
+func (r *Report) addNotAttempted(notAttempted []string) {
+	for _, name := range notAttempted {
+		r.Nodes = append(r.Nodes, &NodeReport{Name: name, Status: StatusNotAttempted, Phases: []*PhaseRecord{}, report: r})
+	}
+}
+
+// StartCanary records the start of the soak of the canary nodes.
+func (r *Report) StartCanary(nodes []string, soak time.Duration) *Canary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Canary = &Canary{Nodes: nodes, SoakSeconds: soak.Seconds(), Status: StatusRunning, StartedAt: time.Now().UTC()}
+	return r.Canary
+}
+
+// Succeed marks the canary as healthy.
+func (c *Canary) Succeed() {
+	c.finish(StatusSucceeded, "", "")
+}
+
+// Fail records why the canary is unhealthy and returns err.
+func (c *Canary) Fail(err error) error {
+	c.finish(StatusFailed, err.Error(), "")
+	return err
+}
+
+// Skip records that the soak was not run.
+func (c *Canary) Skip(reason string) {
+	c.finish(StatusSkipped, "", reason)
+}
+
+func (c *Canary) finish(status Status, errMsg, skipReason string) {
+	now := time.Now().UTC()
+	c.FinishedAt = &now
+	c.Status = status
+	c.Error = errMsg
+	c.SkipReason = skipReason
+}
+
+// Finish stamps the end of the run and computes the totals.
+func (r *Report) Finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC()
+	r.FinishedAt = &now
+	r.Totals = Totals{Nodes: len(r.Nodes)}
+	for _, nr := range r.Nodes {
+		nr.mu.Lock()
+		switch nr.Status {
+		case StatusSucceeded:
+			r.Totals.Succeeded++
+		case StatusFailed:
+			r.Totals.Failed++
+		case StatusNotAttempted:
+			r.Totals.NotAttempted++
+		case StatusSkipped:
+			r.Totals.Skipped++
+		}
+		r.Totals.PodsEvicted += len(nr.PodsEvicted)
+		r.Totals.EvictionRetries += nr.EvictionRetries
+		nr.mu.Unlock()
+	}
+}
+
+// StartPhase records the start of a phase on the node.
+func (n *NodeReport) StartPhase(name Phase) *PhaseRecord {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	p := &PhaseRecord{Name: name, Status: StatusRunning, StartedAt: time.Now().UTC(), node: n}
+	n.Phases = append(n.Phases, p)
+	return p
+}
+
+// SkipPhase records a phase that was not run and why.
+func (n *NodeReport) SkipPhase(name Phase, reason string) {
+	n.StartPhase(name).Skip(reason)
+}
+
+// Phase returns the last record of the named phase, or nil if it never started.
+func (n *NodeReport) Phase(name Phase) *PhaseRecord {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := len(n.Phases) - 1; i >= 0; i-- {
+		if n.Phases[i].Name == name {
+			return n.Phases[i]
+		}
+	}
+	return nil
+}
+
+// FailedPhase returns the phase that stopped the node's restart: the last
+// recorded phase, if it failed.
+func (n *NodeReport) FailedPhase() *PhaseRecord {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.Phases) == 0 {
+		return nil
+	}
+	if p := n.Phases[len(n.Phases)-1]; p.Status == StatusFailed {
+		return p
+	}
+	return nil
+}
+
+// SetPreviouslyCordoned records that the node was cordoned before the run.
+func (n *NodeReport) SetPreviouslyCordoned() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.PreviouslyCordoned = true
+}
+
+// SetOnFailure records the failure policy applied to the node.
+func (n *NodeReport) SetOnFailure(policy string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.OnFailure = policy
+}
+
+// SetSilenceID records the Alertmanager silence created for the node.
+func (n *NodeReport) SetSilenceID(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.SilenceID = id
+}
+
+func (n *NodeReport) SetBootIDs(before, after string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if before != "" {
+		n.BootIDBefore = before
+	}
+	if after != "" {
+		n.BootIDAfter = after
+	}
+}
+
+func (n *NodeReport) AddEvictedPods(pods []string, retries int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.PodsEvicted = append(n.PodsEvicted, pods...)
+	n.EvictionRetries += retries
+}
+
+// Finish marks the node as succeeded, or failed with err.
+func (n *NodeReport) Finish(err error) {
+	n.mu.Lock()
+	now := time.Now().UTC()
+	n.FinishedAt = &now
+	if err != nil {
+		n.Status = StatusFailed
+		n.Error = err.Error()
+	} else {
+		n.Status = StatusSucceeded
+	}
+	n.mu.Unlock()
+
+	if n.report != nil {
+		for _, o := range n.report.observerList() {
+			o.NodeFinished(n)
+		}
+	}
+}
+
+// Stop records that the run was stopped, with err, while the node was in
+// progress, leaving it for a later run: it is reported as not attempted and,
+// as for the nodes never started, observers are not notified.
+func (n *NodeReport) Stop(err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now().UTC()
+	n.FinishedAt = &now
+	n.Status = StatusNotAttempted
+	n.Error = err.Error()
+}
+
+// SetOutput records the output of the command the phase ran.
+func (p *PhaseRecord) SetOutput(out string) {
+	p.node.mu.Lock()
+	defer p.node.mu.Unlock()
+	p.Output = out
+}
+
+func (p *PhaseRecord) Succeed() {
+	p.finish(StatusSucceeded, "", "")
+}
+
+// Fail records err on the phase and returns it, so callers can write
+// `return p.Fail(err)`.
+func (p *PhaseRecord) Fail(err error) error {
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	p.finish(StatusFailed, msg, "")
+	return err
+}
+
+// Skip ends a started phase without running it to completion.
+func (p *PhaseRecord) Skip(reason string) {
+	p.finish(StatusSkipped, "", reason)
+}
+
+func (p *PhaseRecord) finish(status Status, errMsg, skipReason string) {
+	p.node.mu.Lock()
+	p.end(status)
+	p.Error = errMsg
+	p.SkipReason = skipReason
+	p.node.mu.Unlock()
+
+	if p.node.report != nil {
+		for _, o := range p.node.report.observerList() {
+			o.PhaseEnded(p.node.Name, p)
+		}
+	}
+}
+
+// Duration is the time the phase has taken so far, or in total once ended,
+// rounded to milliseconds.
+func (p *PhaseRecord) Duration() time.Duration {
+	p.node.mu.Lock()
+	defer p.node.mu.Unlock()
+	end := time.Now()
+	if p.FinishedAt != nil {
+		end = *p.FinishedAt
+	}
+	return end.Sub(p.StartedAt).Round(time.Millisecond)
+}
+
+func (p *PhaseRecord) end(status Status) {
+	now := time.Now().UTC()
+	p.Status = status
+	p.FinishedAt = &now
+	p.DurationSeconds = now.Sub(p.StartedAt).Seconds()
+}
+
+// ValidateFormat reports whether f is a supported output format.
+func ValidateFormat(f string) error {
+	switch f {
+	case FormatJSON, FormatYAML:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want %s or %s)", f, FormatJSON, FormatYAML)
+}
+
+// Write encodes the report to w as JSON or YAML.
+func (r *Report) Write(w io.Writer, format string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case FormatJSON:
+		data = append(data, '\n')
+	case FormatYAML:
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+	default:
+		return ValidateFormat(format)
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteFile writes the report to path. The format follows the file
+// extension (.yaml/.yml for YAML) unless format is set.
+func (r *Report) WriteFile(path, format string) error {
+	if format == "" {
+		format = FormatJSON
+		if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+			format = FormatYAML
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.Write(f, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,248 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNodeReportPhases(t *testing.T) {
+	r := New(false)
+	nr := r.Node("node1")
+
+	nr.StartPhase(PhaseCordon).Succeed()
+	nr.SkipPhase(PhaseReboot, "reboot method none")
+	p := nr.StartPhase(PhaseWaitReady)
+	err := p.Fail(errors.New("timeout"))
+	if err == nil || err.Error() != "timeout" {
+		t.Errorf("Fail() should return its error, got %v", err)
+	}
+	nr.Finish(err)
+
+	if nr.Status != StatusFailed || nr.Error != "timeout" || nr.FinishedAt == nil {
+		t.Errorf("unexpected node report %+v", nr)
+	}
+	want := []struct {
+		name   Phase
+		status Status
+	}{
+		{PhaseCordon, StatusSucceeded},
+		{PhaseReboot, StatusSkipped},
+		{PhaseWaitReady, StatusFailed},
+	}
+	if len(nr.Phases) != len(want) {
+		t.Fatalf("Expected %d phases, got %d", len(want), len(nr.Phases))
+	}
+	for i, w := range want {
+		got := nr.Phases[i]
+		if got.Name != w.name || got.Status != w.status || got.FinishedAt == nil {
+			t.Errorf("phase %d = %s/%s, want %s/%s", i, got.Name, got.Status, w.name, w.status)
+		}
+	}
+	if nr.Phases[1].SkipReason != "reboot method none" {
+		t.Errorf("skip reason not recorded: %q", nr.Phases[1].SkipReason)
+	}
+}
+
+func TestReportTotals(t *testing.T) {
+	r := New(true)
+	ok := r.Node("node1")
+	ok.AddEvictedPods([]string{"default/web-1", "default/web-2"}, 0)
+	ok.Finish(nil)
+	bad := r.Node("node2")
+	bad.AddEvictedPods([]string{"default/db-0"}, 2)
+	bad.Finish(errors.New("evict: timeout"))
+	r.Finish()
+
+	want := Totals{Nodes: 2, Succeeded: 1, Failed: 1, PodsEvicted: 3, EvictionRetries: 2}
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+	if r.FinishedAt == nil {
+		t.Error("FinishedAt not set")
+	}
+}
+
+func TestReportAbort(t *testing.T) {
+	r := New(false)
+	r.Node("node1").Finish(errors.New("boom"))
+	r.Abort([]string{"node2", "node3"})
+	r.Finish()
+
+	if !r.Aborted {
+		t.Error("Aborted not set")
+	}
+	want := Totals{Nodes: 3, Failed: 1, NotAttempted: 2}
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+	if r.Nodes[2].Status != StatusNotAttempted || r.Nodes[2].StartedAt != nil {
+		t.Errorf("unexpected not-attempted node %+v", r.Nodes[2])
+	}
+}
+
+type recordingObserver struct {
+	finished []string
+}
+
+func (o *recordingObserver) PhaseEnded(string, *PhaseRecord) {}
+
+func (o *recordingObserver) NodeFinished(n *NodeReport) {
+	o.finished = append(o.finished, n.Name)
+}
+
+func TestNodeReportStop(t *testing.T) {
+	r := New(false)
+	obs := &recordingObserver{}
+	r.AddObserver(obs)
+	r.Node("node1").Stop(errors.New("context canceled"))
+	r.Finish()
+
+	want := Totals{Nodes: 1, NotAttempted: 1}
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+	if nr := r.Nodes[0]; nr.Error != "context canceled" || nr.FinishedAt == nil {
+		t.Errorf("unexpected stopped node %+v", nr)
+	}
+	if len(obs.finished) != 0 {
+		t.Errorf("observers notified of %v, want none", obs.finished)
+	}
+}
+
+func TestReportRetryAndSkip(t *testing.T) {
+	r := New(false)
+	r.Node("node1").Finish(errors.New("boom"))
+	r.Node("node1").Finish(nil)
+	r.SkipNodes([]string{"node2"})
+	r.Finish()
+
+	want := Totals{Nodes: 2, Succeeded: 1, Skipped: 1}
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+	if nr := r.Lookup("node1"); nr == nil || nr.Retries != 1 || nr.Error != "" {
+		t.Errorf("unexpected retried node %+v", nr)
+	}
+	if nr := r.Lookup("node2"); nr == nil || nr.Status != StatusSkipped {
+		t.Errorf("unexpected skipped node %+v", nr)
+	}
+	if r.Lookup("node3") != nil {
+		t.Error("Lookup() found a node that is not in the report")
+	}
+}
+
+func TestReportProgress(t *testing.T) {
+	r := New(false)
+	nr := r.Node("node1")
+	nr.StartPhase(PhaseCordon).Succeed()
+	nr.StartPhase(PhaseDrain)
+	nr.AddEvictedPods([]string{"default/web"}, 0)
+	r.Node("node2").Finish(errors.New("boom"))
+	r.Abort([]string{"node3"})
+
+	got := r.Progress()
+	if len(got) != 3 {
+		t.Fatalf("Progress() returned %d nodes", len(got))
+	}
+	if got[0].Status != StatusRunning || got[0].Phase != PhaseDrain || got[0].Evicted != 1 || got[0].StartedAt == nil {
+		t.Errorf("unexpected progress for node1: %+v", got[0])
+	}
+	if got[1].Status != StatusFailed || got[1].Error != "boom" || got[1].FinishedAt == nil {
+		t.Errorf("unexpected progress for node2: %+v", got[1])
+	}
+	if got[2].Status != StatusNotAttempted || got[2].Phase != "" {
+		t.Errorf("unexpected progress for node3: %+v", got[2])
+	}
+}
+
+func TestReportCloseWindow(t *testing.T) {
+	r := New(false)
+	r.Node("node1").Finish(nil)
+	r.CloseWindow([]string{"node2"})
+	r.Finish()
+
+	if !r.WindowClosed || r.Aborted {
+		t.Errorf("WindowClosed = %v, Aborted = %v; want true, false", r.WindowClosed, r.Aborted)
+	}
+	want := Totals{Nodes: 2, Succeeded: 1, NotAttempted: 1}
+	if r.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", r.Totals, want)
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	r := New(false)
+	nr := r.Node("node1")
+	nr.SetBootIDs("before", "after")
+	nr.Finish(nil)
+	r.Finish()
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf, FormatJSON); err != nil {
+		t.Fatalf("Write(json) error = %v", err)
+	}
+	var decoded struct {
+		Nodes []struct {
+			Name         string `json:"name"`
+			Status       string `json:"status"`
+			BootIDBefore string `json:"bootIDBefore"`
+			BootIDAfter  string `json:"bootIDAfter"`
+		} `json:"nodes"`
+		Totals Totals `json:"totals"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.Nodes) != 1 || decoded.Nodes[0].Status != "succeeded" || decoded.Nodes[0].BootIDAfter != "after" {
+		t.Errorf("unexpected decoded report %+v", decoded)
+	}
+
+	buf.Reset()
+	if err := r.Write(&buf, FormatYAML); err != nil {
+		t.Fatalf("Write(yaml) error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "bootIDBefore: before") {
+		t.Errorf("unexpected YAML:\n%s", buf.String())
+	}
+
+	if err := r.Write(&buf, "xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestWriteFileFormatFromExtension(t *testing.T) {
+	r := New(false)
+	r.Finish()
+	dir := t.TempDir()
+
+	tests := []struct {
+		file     string
+		format   string
+		wantJSON bool
+	}{
+		{file: "report.json", wantJSON: true},
+		{file: "report.yaml", wantJSON: false},
+		{file: "report.yml", wantJSON: false},
+		{file: "report.out", format: FormatYAML, wantJSON: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := r.WriteFile(path, tt.format); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isJSON := bytes.HasPrefix(data, []byte("{")); isJSON != tt.wantJSON {
+				t.Errorf("%s: JSON = %v, want %v", tt.file, isJSON, tt.wantJSON)
+			}
+		})
+	}
+}
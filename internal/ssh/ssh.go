@@ -1,12 +1,15 @@
 package ssh
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/charmbracelet/log"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -16,43 +19,30 @@ type Runner struct {
 	Key    string
 }
 
-func (r *Runner) Run(host, command string, logf func(string, ...any)) error {
+// Run runs command on host, logging to logger, which carries the node and
+// phase; a nil logger logs nothing.
+func (r *Runner) Run(logger *log.Logger, host, command string) error {
 	if r.DryRun {
-		logf("🧪 SSH command (dry-run): ssh %s %s", host, command)
+		info(logger, "SSH command skipped (dry-run)", "host", host, "command", command)
 		return nil
 	}
 
-	logf("🔗 Executing SSH command on %s: %s", host, command)
+	start := time.Now()
+	info(logger, "Executing SSH command", "host", host, "command", command)
 
-	// Parse user and hostname
-	user, hostname := parseHost(host)
-
-	// Create SSH config
-	config := &ssh.ClientConfig{
-		User:            user,
-		HostKeyCallback: r.getHostKeyCallback(),
-		Timeout:         10 * time.Second,
-		Auth:            r.getAuthMethods(),
-	}
-
-	// Connect and run command
-	client, err := ssh.Dial("tcp", hostname+":22", config)
+	client, err := r.dial(host)
 	if err != nil {
-		return fmt.Errorf("❌ SSH connection failed to %s: %v", host, err)
+		return err
 	}
-	defer func() {
-		if closeErr := client.Close(); closeErr != nil {
-			logf("⚠️  Warning: Failed to close SSH client: %v", closeErr)
-		}
-	}()
+	defer closeClient(logger, client, host)
 
 	session, err := client.NewSession()
 	if err != nil {
-		return fmt.Errorf("❌ SSH session failed: %v", err)
+		return fmt.Errorf("SSH session failed: %v", err)
 	}
 	defer func() {
 		if closeErr := session.Close(); closeErr != nil {
-			logf("⚠️  Warning: Failed to close SSH session: %v", closeErr)
+			warn(logger, "Failed to close SSH session", "host", host, "error", closeErr)
 		}
 	}()
 
@@ -61,16 +51,128 @@ func (r *Runner) Run(host, command string, logf func(string, ...any)) error {
 	if err != nil {
 		// For reboot, connection loss is expected
 		if strings.Contains(strings.ToLower(command), "reboot") {
-			logf("✅ SSH reboot command sent successfully on %s", host)
+			info(logger, "SSH reboot command sent", "host", host, "duration", time.Since(start))
 			return nil
 		}
-		return fmt.Errorf("❌ SSH command failed on %s: %v", host, err)
+		return fmt.Errorf("SSH command failed on %s: %v", host, err)
 	}
 
-	logf("✅ SSH command completed successfully on %s", host)
+	info(logger, "SSH command completed", "host", host, "duration", time.Since(start))
 	return nil
 }
 
+// maxOutput bounds the output kept from a command run by Output.
+const maxOutput = 16 << 10
+
+// Output runs command on host, logging to logger like Run, and returns its combined stdout and stderr,
+// keeping at most the last 16 KiB. The command is abandoned with an error
+// if it has not finished within timeout; 0 means no timeout.
+func (r *Runner) Output(logger *log.Logger, host, command string, timeout time.Duration) (string, error) {
+	if r.DryRun {
+		info(logger, "SSH command skipped (dry-run)", "host", host, "command", command)
+		return "", nil
+	}
+
+	start := time.Now()
+	info(logger, "Executing SSH command", "host", host, "command", command)
+
+	client, err := r.dial(host)
+	if err != nil {
+		return "", err
+	}
+	defer closeClient(logger, client, host)
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("SSH session failed: %v", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	out := &tailBuffer{max: maxOutput}
+	session.Stdout = out
+	session.Stderr = out
+
+	done := make(chan error, 1)
+	go func() { done <- session.Run(command) }()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case err = <-done:
+	case <-expired:
+		// Closing the connection unblocks session.Run.
+		_ = client.Close()
+		return out.String(), fmt.Errorf("SSH command on %s timed out after %s", host, timeout)
+	}
+	if err != nil {
+		return out.String(), fmt.Errorf("SSH command failed on %s: %v", host, err)
+	}
+
+	info(logger, "SSH command completed", "host", host, "duration", time.Since(start))
+	return out.String(), nil
+}
+
+func (r *Runner) dial(host string) (*ssh.Client, error) {
+	user, hostname := parseHost(host)
+	config := &ssh.ClientConfig{
+		User:            user,
+		HostKeyCallback: r.getHostKeyCallback(),
+		Timeout:         10 * time.Second,
+		Auth:            r.getAuthMethods(),
+	}
+	client, err := ssh.Dial("tcp", hostname+":22", config)
+	if err != nil {
+		return nil, fmt.Errorf("SSH connection failed to %s: %v", host, err)
+	}
+	return client, nil
+}
+
+func closeClient(logger *log.Logger, client *ssh.Client, host string) {
+	if err := client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		warn(logger, "Failed to close SSH client", "host", host, "error", err)
+	}
+}
+
+// tailBuffer is an io.Writer keeping the last max bytes written to it. It is
+// safe for the concurrent writes of a session's stdout and stderr.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+func info(logger *log.Logger, msg string, keyvals ...any) {
+	if logger != nil {
+		logger.Info(msg, keyvals...)
+	}
+}
+
+func warn(logger *log.Logger, msg string, keyvals ...any) {
+	if logger != nil {
+		logger.Warn(msg, keyvals...)
+	}
+}
+
 func (r *Runner) getAuthMethods() []ssh.AuthMethod {
 	var authMethods []ssh.AuthMethod
 
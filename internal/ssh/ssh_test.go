@@ -1,8 +1,11 @@
 package ssh
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
+	"github.com/charmbracelet/log"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -79,38 +82,60 @@ func TestParseHost(t *testing.T) {
 }
 
 func TestRunnerDryRun(t *testing.T) {
+	// Capture log output
+	var buf bytes.Buffer
+	logger := log.NewWithOptions(&buf, log.Options{Formatter: log.JSONFormatter})
+
 	runner := &Runner{
 		DryRun: true,
 		Opts:   "-o StrictHostKeyChecking=no",
 		Key:    "",
 	}
 
-	// Capture log output
-	var loggedMessages []string
-	logf := func(format string, _ ...any) {
-		loggedMessages = append(loggedMessages, format)
-	}
-
-	err := runner.Run("testhost", "sudo reboot", logf)
+	err := runner.Run(logger.With("node", "node1", "phase", "reboot"), "testhost", "sudo reboot")
 	if err != nil {
 		t.Errorf("Expected no error in dry-run mode, got: %v", err)
 	}
 
-	if len(loggedMessages) == 0 {
-		t.Error("Expected log message in dry-run mode")
+	if buf.Len() == 0 {
+		t.Fatal("Expected log message in dry-run mode")
 	}
 
-	// Check if log message contains expected dry-run indicator
-	found := false
-	for _, msg := range loggedMessages {
-		if containsPattern(msg, "dry-run") {
-			found = true
-			break
+	// Check if log message contains expected dry-run indicator and fields
+	if !containsPattern(buf.String(), "dry-run") {
+		t.Error("Expected dry-run log message not found")
+	}
+	for _, field := range []string{`"host":"testhost"`, `"node":"node1"`, `"phase":"reboot"`} {
+		if !containsPattern(buf.String(), field) {
+			t.Errorf("Expected %s in log output, got %s", field, buf.String())
 		}
 	}
+}
 
-	if !found {
-		t.Error("Expected dry-run log message not found")
+func TestRunnerDryRunWithoutLogger(t *testing.T) {
+	runner := &Runner{DryRun: true}
+	if err := runner.Run(nil, "testhost", "sudo reboot"); err != nil {
+		t.Errorf("Expected no error in dry-run mode, got: %v", err)
+	}
+}
+
+func TestRunnerOutputDryRun(t *testing.T) {
+	runner := &Runner{DryRun: true}
+	out, err := runner.Output(nil, "testhost", "nvidia-smi -pm 0", time.Second)
+	if err != nil || out != "" {
+		t.Errorf("Output() = %q, %v; want no output and no error in dry-run mode", out, err)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 8}
+	for _, s := range []string{"abc", "defgh", "ijk"} {
+		if n, err := b.Write([]byte(s)); n != len(s) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", s, n, err)
+		}
+	}
+	if got := b.String(); got != "defghijk" {
+		t.Errorf("String() = %q, want the last 8 bytes %q", got, "defghijk")
 	}
 }
 
@@ -0,0 +1,193 @@
+// Package window parses maintenance windows and computes when they open and
+// close.
+package window
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is a set of daily time ranges on selected weekdays, each in its own
+// time zone.
+type Window struct {
+	ranges []*timeRange
+	spec   string
+}
+
+// timeRange is one daily range of a Window. A range ending at or before its
+// start crosses midnight and belongs to the day it starts on.
+type timeRange struct {
+	days  [7]bool // indexed by time.Weekday
+	start int     // minutes after midnight
+	end   int
+	loc   *time.Location
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse parses "[DAYS] HH:MM-HH:MM [TIMEZONE]", for example
+//
+//	Mon-Fri 01:00-05:00 Europe/Istanbul
+//	Sat,Sun 22:00-06:00
+//	02:00-04:00 UTC
+//
+// DAYS is a comma-separated list of days and day ranges and defaults to
+// every day; TIMEZONE is an IANA name and defaults to the local time zone.
+// Several ranges may be given separated by semicolons.
+func Parse(s string) (*Window, error) {
+	w := &Window{spec: s}
+	for _, part := range strings.Split(s, ";") {
+		r, err := parseRange(part)
+		if err != nil {
+			return nil, fmt.Errorf("--window %q: %w", s, err)
+		}
+		w.ranges = append(w.ranges, r)
+	}
+	return w, nil
+}
+
+func parseRange(s string) (*timeRange, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("want [DAYS] HH:MM-HH:MM [TIMEZONE], got %q", strings.TrimSpace(s))
+	}
+	w := &timeRange{loc: time.Local}
+	i := 0
+	if !strings.Contains(fields[0], ":") {
+		if err := w.parseDays(fields[0]); err != nil {
+			return nil, err
+		}
+		i++
+	} else {
+		for d := range w.days {
+			w.days[d] = true
+		}
+	}
+	if i >= len(fields) {
+		return nil, fmt.Errorf("missing HH:MM-HH:MM in %q", strings.TrimSpace(s))
+	}
+	from, to, ok := strings.Cut(fields[i], "-")
+	if !ok {
+		return nil, fmt.Errorf("want HH:MM-HH:MM, got %q", fields[i])
+	}
+	var err error
+	if w.start, err = parseClock(from, false); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseClock(to, true); err != nil {
+		return nil, err
+	}
+	if w.start == w.end {
+		return nil, fmt.Errorf("empty time range %q", fields[i])
+	}
+	i++
+	if i < len(fields) {
+		if w.loc, err = time.LoadLocation(fields[i]); err != nil {
+			return nil, err
+		}
+		i++
+	}
+	if i != len(fields) {
+		return nil, fmt.Errorf("unexpected %q", fields[i])
+	}
+	return w, nil
+}
+
+func (w *timeRange) parseDays(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, ok := weekdays[strings.ToLower(from)]
+		if !ok {
+			return fmt.Errorf("unknown day %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[strings.ToLower(to)]; !ok {
+				return fmt.Errorf("unknown day %q", to)
+			}
+		}
+		// Ranges may wrap around the week, e.g. Fri-Mon.
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 is accepted as
+// an end time.
+func parseClock(s string, isEnd bool) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || mm < 0 || mm > 59 || hh > 24 || (hh == 24 && (mm != 0 || !isEnd)) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hh*60 + mm, nil
+}
+
+func (w *Window) String() string {
+	return w.spec
+}
+
+// Next returns the occurrence of the window that contains t or, if t is
+// outside the window, the next one to open. Of several ranges containing t,
+// the one closing last is returned.
+func (w *Window) Next(t time.Time) (opens, closes time.Time) {
+	for _, r := range w.ranges {
+		o, c := r.next(t)
+		switch {
+		case opens.IsZero():
+			opens, closes = o, c
+		case !o.After(t):
+			// r contains t.
+			if opens.After(t) || c.After(closes) {
+				opens, closes = o, c
+			}
+		case opens.After(t) && o.Before(opens):
+			opens, closes = o, c
+		}
+	}
+	return opens, closes
+}
+
+// Remaining returns how long the window stays open after t, or 0 if t is
+// outside the window.
+func (w *Window) Remaining(t time.Time) time.Duration {
+	opens, closes := w.Next(t)
+	if opens.After(t) {
+		return 0
+	}
+	return closes.Sub(t)
+}
+
+func (w *timeRange) next(t time.Time) (opens, closes time.Time) {
+	t = t.In(w.loc)
+	y, m, d := t.Date()
+	// Start a day early for a range that crossed midnight into today.
+	for offset := -1; offset <= 7; offset++ {
+		day := time.Date(y, m, d+offset, 0, 0, 0, 0, w.loc)
+		if !w.days[day.Weekday()] {
+			continue
+		}
+		opens = time.Date(y, m, d+offset, 0, w.start, 0, 0, w.loc)
+		endDay := d + offset
+		if w.end <= w.start {
+			endDay++
+		}
+		closes = time.Date(y, m, endDay, 0, w.end, 0, 0, w.loc)
+		if closes.After(t) {
+			return opens, closes
+		}
+	}
+	// Unreachable: parseRange guarantees at least one day.
+	return time.Time{}, time.Time{}
+}
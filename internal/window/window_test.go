@@ -0,0 +1,80 @@
+package window
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{spec: "Mon-Fri 01:00-05:00 Europe/Istanbul"},
+		{spec: "sat,sun 22:00-06:00"},
+		{spec: "Fri-Mon,Wed 00:00-24:00 UTC"},
+		{spec: "02:00-04:00 UTC"},
+		{spec: "Mon-Fri 01:00-05:00 UTC; Sat,Sun 00:00-08:00 UTC"},
+		{spec: "Mon-Fri 01:00-05:00 UTC;", wantErr: true},
+		{spec: "", wantErr: true},
+		{spec: "Mon-Fri", wantErr: true},
+		{spec: "Mon-Fry 01:00-05:00", wantErr: true},
+		{spec: "01:00-01:00", wantErr: true},
+		{spec: "01:00-25:00", wantErr: true},
+		{spec: "24:00-02:00", wantErr: true},
+		{spec: "01:00-05:00 Mars/Olympus", wantErr: true},
+		{spec: "Mon 01:00-05:00 UTC extra", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNextAndRemaining(t *testing.T) {
+	utc := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	// 2025-10-03 is a Friday.
+	tests := []struct {
+		name          string
+		spec          string
+		at            string
+		wantOpens     string
+		wantRemaining time.Duration
+	}{
+		{name: "inside", spec: "Mon-Fri 01:00-05:00 UTC", at: "2025-10-03 02:30", wantOpens: "2025-10-03 01:00", wantRemaining: 150 * time.Minute},
+		{name: "before opening", spec: "Mon-Fri 01:00-05:00 UTC", at: "2025-10-03 00:10", wantOpens: "2025-10-03 01:00"},
+		{name: "after closing skips the weekend", spec: "Mon-Fri 01:00-05:00 UTC", at: "2025-10-03 05:00", wantOpens: "2025-10-06 01:00"},
+		{name: "crossing midnight, after midnight", spec: "Fri 22:00-02:00 UTC", at: "2025-10-04 01:00", wantOpens: "2025-10-03 22:00", wantRemaining: time.Hour},
+		{name: "crossing midnight belongs to the start day", spec: "Fri 22:00-02:00 UTC", at: "2025-10-03 01:00", wantOpens: "2025-10-03 22:00"},
+		{name: "second range", spec: "Mon-Fri 01:00-05:00 UTC; Sat,Sun 00:00-08:00 UTC", at: "2025-10-04 07:00", wantOpens: "2025-10-04 00:00", wantRemaining: time.Hour},
+		{name: "earliest next range", spec: "Mon-Fri 01:00-05:00 UTC; Sat,Sun 00:00-08:00 UTC", at: "2025-10-03 06:00", wantOpens: "2025-10-04 00:00"},
+		{name: "overlapping ranges close last", spec: "Fri 01:00-03:00 UTC; Fri 02:00-06:00 UTC", at: "2025-10-03 02:30", wantOpens: "2025-10-03 02:00", wantRemaining: 210 * time.Minute},
+		{name: "time zone", spec: "Mon-Fri 01:00-05:00 Europe/Istanbul", at: "2025-10-02 23:00", wantOpens: "2025-10-02 22:00", wantRemaining: 3 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			at := utc(tt.at)
+			opens, _ := w.Next(at)
+			if !opens.Equal(utc(tt.wantOpens)) {
+				t.Errorf("Next(%s) opens %s, want %s UTC", tt.at, opens.UTC(), tt.wantOpens)
+			}
+			if got := w.Remaining(at); got != tt.wantRemaining {
+				t.Errorf("Remaining(%s) = %s, want %s", tt.at, got, tt.wantRemaining)
+			}
+		})
+	}
+}